@@ -0,0 +1,296 @@
+package money
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TraceNode is a span positioned within an assembled trace.
+type TraceNode struct {
+	Span     Span
+	Parent   *TraceNode
+	Children []*TraceNode
+
+	// SelfTime is the portion of the span's duration not covered by any of its children
+	SelfTime time.Duration
+
+	// Skew is the adjustment which was applied to the start time of the span
+	// to correct the clock of its host. It is only set under WithSkewCorrection;
+	// a non-zero skew is also recorded on the span as the clock-skew attribute.
+	Skew time.Duration
+}
+
+// End returns the time at which the span of this node finished
+func (n *TraceNode) End() time.Time {
+	return n.Span.StartTime.Add(n.Span.Duration)
+}
+
+// Trace is the call tree of all spans sharing a trace-id.
+type Trace struct {
+	TID string
+
+	// Roots are the spans which start the trace, that is, those without a parent
+	// or whose parent-id is their own span-id
+	Roots []*TraceNode
+
+	// Orphans are spans whose parent could not be found among the given spans.
+	// They keep their own children.
+	Orphans []*TraceNode
+
+	// Duplicates are spans whose span-id was already taken by another span of the trace.
+	// The client and server spans of an HTTP call share their span-id so they are
+	// expected here; the span with the longest duration is the one kept in the tree.
+	Duplicates []*TraceNode
+
+	// CriticalPath is the chain of spans, from a root down to a leaf, formed by
+	// following the child which finished last at every level
+	CriticalPath []*TraceNode
+}
+
+// skewKey is the attribute recording the clock skew correction applied to a span
+const skewKey = "clock-skew"
+
+// AssemblyOptions configure how traces are assembled
+type AssemblyOptions func(*assembler)
+
+// WithSkewCorrection corrects the clock skew between the hosts of a trace.
+// The client and server spans of an HTTP call share their span-id; whenever a server
+// span does not fit within its client span, all spans of the server's host are shifted
+// such that the server span is centered within the client span. Hosts are corrected
+// relative to the host of the trace root.
+func WithSkewCorrection() AssemblyOptions {
+	return func(a *assembler) {
+		a.correctSkew = true
+	}
+}
+
+type assembler struct {
+	correctSkew bool
+}
+
+// BuildTrace decodes the given string-encoded spans, such as those returned by
+// Tracker.Spans, and assembles them into one call tree per trace-id.
+func BuildTrace(spans []string, options ...AssemblyOptions) ([]*Trace, error) {
+	decoded := make([]Span, 0, len(spans))
+
+	for i, raw := range spans {
+		s, err := ParseSpan(raw)
+		if err != nil {
+			return nil, fmt.Errorf("span %d: %v", i, err)
+		}
+
+		decoded = append(decoded, s)
+	}
+
+	return AssembleTraces(decoded, options...), nil
+}
+
+// AssembleTraces groups spans by trace-id and links them into call trees through their
+// parent-id and span-id. Traces are sorted by trace-id.
+func AssembleTraces(spans []Span, options ...AssemblyOptions) []*Trace {
+	var (
+		a      = new(assembler)
+		groups = make(map[string][]Span)
+		tids   []string
+	)
+
+	for _, o := range options {
+		o(a)
+	}
+
+	for _, s := range spans {
+		if s.TC == nil {
+			continue
+		}
+
+		if _, ok := groups[s.TC.TID]; !ok {
+			tids = append(tids, s.TC.TID)
+		}
+
+		groups[s.TC.TID] = append(groups[s.TC.TID], s)
+	}
+
+	sort.Strings(tids)
+
+	traces := make([]*Trace, 0, len(tids))
+	for _, tid := range tids {
+		traces = append(traces, a.assemble(tid, groups[tid]))
+	}
+
+	return traces
+}
+
+func (a *assembler) assemble(tid string, spans []Span) *Trace {
+	var (
+		t     = &Trace{TID: tid}
+		nodes = make(map[int64]*TraceNode)
+		order []*TraceNode
+	)
+
+	for _, s := range spans {
+		n := &TraceNode{Span: s}
+
+		if kept, ok := nodes[s.TC.SID]; ok {
+			if s.Duration > kept.Span.Duration {
+				nodes[s.TC.SID], n = n, kept
+				order[indexOf(order, kept)] = nodes[s.TC.SID]
+			}
+
+			t.Duplicates = append(t.Duplicates, n)
+			continue
+		}
+
+		nodes[s.TC.SID] = n
+		order = append(order, n)
+	}
+
+	for _, n := range order {
+		var tc = n.Span.TC
+
+		switch parent, ok := nodes[tc.PID]; {
+		case isRoot(tc):
+			t.Roots = append(t.Roots, n)
+		case ok:
+			n.Parent = parent
+			parent.Children = append(parent.Children, n)
+		default:
+			t.Orphans = append(t.Orphans, n)
+		}
+	}
+
+	if a.correctSkew && len(order) > 0 {
+		reference := order[0]
+		if len(t.Roots) > 0 {
+			reference = t.Roots[0]
+		}
+
+		correctSkew(reference, nodes, t.Duplicates)
+	}
+
+	for _, n := range order {
+		sortByStart(n.Children)
+		n.SelfTime = selfTime(n)
+	}
+
+	sortByStart(t.Roots)
+	sortByStart(t.Orphans)
+	t.CriticalPath = criticalPath(t.Roots)
+
+	return t
+}
+
+// correctSkew estimates the offset of every host relative to the host of reference
+// through the client/server span pairs of the trace and applies it to all nodes.
+// Duplicates are the server spans, nodes hold their clients.
+func correctSkew(reference *TraceNode, nodes map[int64]*TraceNode, duplicates []*TraceNode) {
+	var skews = map[string]time.Duration{reference.Span.Host: 0}
+
+	for progress := true; progress; {
+		var deltas = make(map[string][]time.Duration)
+
+		for _, server := range duplicates {
+			client := nodes[server.Span.TC.SID]
+			clientSkew, known := skews[client.Span.Host]
+			if _, done := skews[server.Span.Host]; !known || done || server.Span.Host == "" {
+				continue
+			}
+
+			cs, ss := client.Span.StartTime.Add(clientSkew), server.Span.StartTime
+			ce, se := cs.Add(client.Span.Duration), ss.Add(server.Span.Duration)
+
+			var delta time.Duration
+			if ss.Before(cs) || se.After(ce) {
+				delta = cs.Add((client.Span.Duration - server.Span.Duration) / 2).Sub(ss)
+			}
+
+			deltas[server.Span.Host] = append(deltas[server.Span.Host], delta)
+		}
+
+		for host, ds := range deltas {
+			sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+			skews[host] = ds[len(ds)/2]
+		}
+
+		progress = len(deltas) > 0
+	}
+
+	adjust := func(n *TraceNode) {
+		n.Skew = skews[n.Span.Host]
+		if n.Skew == 0 {
+			return
+		}
+
+		n.Span.StartTime = n.Span.StartTime.Add(n.Skew)
+		n.Span.Attributes = mergeAttributes(n.Span.Attributes, map[string]string{skewKey: n.Skew.String()})
+	}
+
+	for _, n := range nodes {
+		adjust(n)
+	}
+
+	for _, n := range duplicates {
+		adjust(n)
+	}
+}
+
+func indexOf(nodes []*TraceNode, n *TraceNode) int {
+	for i := range nodes {
+		if nodes[i] == n {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func sortByStart(nodes []*TraceNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Span.StartTime.Before(nodes[j].Span.StartTime)
+	})
+}
+
+// selfTime subtracts the union of the children's intervals, clipped to the
+// interval of n, from the duration of n. Children must be sorted by start time.
+func selfTime(n *TraceNode) time.Duration {
+	var (
+		start, end = n.Span.StartTime, n.End()
+		covered    time.Duration
+		cursor     = start
+	)
+
+	for _, c := range n.Children {
+		cs, ce := c.Span.StartTime, c.End()
+
+		if cs.Before(cursor) {
+			cs = cursor
+		}
+
+		if ce.After(end) {
+			ce = end
+		}
+
+		if ce.After(cs) {
+			covered += ce.Sub(cs)
+			cursor = ce
+		}
+	}
+
+	return n.Span.Duration - covered
+}
+
+func criticalPath(candidates []*TraceNode) (path []*TraceNode) {
+	for len(candidates) > 0 {
+		last := candidates[0]
+		for _, c := range candidates[1:] {
+			if c.End().After(last.End()) {
+				last = c
+			}
+		}
+
+		path = append(path, last)
+		candidates = last.Children
+	}
+
+	return
+}
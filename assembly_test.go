@@ -0,0 +1,131 @@
+package money
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var assemblyEpoch = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+func createAssemblySpan(name string, tid string, pid, sid int64, start, duration time.Duration) Span {
+	return Span{
+		Name:      name,
+		AppName:   "test-app",
+		TC:        &TraceContext{TID: tid, PID: pid, SID: sid},
+		Success:   true,
+		StartTime: assemblyEpoch.Add(start),
+		Duration:  duration,
+	}
+}
+
+func names(nodes []*TraceNode) (n []string) {
+	for _, node := range nodes {
+		n = append(n, node.Span.Name)
+	}
+	return
+}
+
+func TestBuildTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	var spans []string
+	for _, s := range []Span{
+		createAssemblySpan("root", "a", 1, 1, 0, 100*time.Millisecond),
+		createAssemblySpan("first", "a", 1, 2, 10*time.Millisecond, 30*time.Millisecond),
+		createAssemblySpan("second", "a", 1, 3, 20*time.Millisecond, 70*time.Millisecond),
+		createAssemblySpan("nested", "a", 3, 4, 30*time.Millisecond, 20*time.Millisecond),
+		createAssemblySpan("lost", "a", 9, 5, 0, time.Millisecond),
+		createAssemblySpan("other", "b", 0, 7, 0, time.Millisecond),
+	} {
+		spans = append(spans, s.String())
+	}
+
+	traces, err := BuildTrace(spans)
+	assert.Nil(err)
+	assert.Len(traces, 2)
+
+	a := traces[0]
+	assert.Equal("a", a.TID)
+	assert.Equal([]string{"root"}, names(a.Roots))
+	assert.Equal([]string{"lost"}, names(a.Orphans))
+	assert.Equal([]string{"first", "second"}, names(a.Roots[0].Children))
+	assert.Equal([]string{"root", "second", "nested"}, names(a.CriticalPath))
+
+	// children cover 10ms-90ms of the root
+	assert.Equal(20*time.Millisecond, a.Roots[0].SelfTime)
+	assert.Equal(50*time.Millisecond, a.Roots[0].Children[1].SelfTime)
+	assert.Equal(a.Roots[0], a.Roots[0].Children[0].Parent)
+
+	b := traces[1]
+	assert.Equal("b", b.TID)
+	assert.Equal([]string{"other"}, names(b.Roots))
+}
+
+func TestBuildTraceDuplicates(t *testing.T) {
+	assert := assert.New(t)
+
+	//a server span is returned before the enclosing client span which shares its span-id
+	server := createAssemblySpan("server", "a", 1, 2, 12*time.Millisecond, 10*time.Millisecond)
+	client := createAssemblySpan("client", "a", 1, 2, 10*time.Millisecond, 15*time.Millisecond)
+	root := createAssemblySpan("root", "a", 1, 1, 0, 30*time.Millisecond)
+
+	traces := AssembleTraces([]Span{server, client, root})
+	assert.Len(traces, 1)
+	assert.Equal([]string{"client"}, names(traces[0].Roots[0].Children))
+	assert.Equal([]string{"server"}, names(traces[0].Duplicates))
+}
+
+func TestBuildTraceBadSpan(t *testing.T) {
+	_, err := BuildTrace([]string{"not-a-span"})
+	assert.Error(t, err)
+}
+
+func TestBuildTraceSkewCorrection(t *testing.T) {
+	var (
+		root   = createAssemblySpan("root", "a", 1, 1, 0, 100*time.Millisecond)
+		client = createAssemblySpan("client", "a", 1, 2, 10*time.Millisecond, 40*time.Millisecond)
+		local  = createAssemblySpan("local", "a", 1, 3, 60*time.Millisecond, 10*time.Millisecond)
+
+		//the clock of host-b runs behind the one of host-a
+		server = createAssemblySpan("server", "a", 1, 2, -40*time.Millisecond, 20*time.Millisecond)
+		child  = createAssemblySpan("child", "a", 2, 4, -35*time.Millisecond, 10*time.Millisecond)
+	)
+
+	root.Host, client.Host, local.Host = "host-a", "host-a", "host-a"
+	server.Host, child.Host = "host-b", "host-b"
+
+	t.Run("Disabled", func(t *testing.T) {
+		traces := AssembleTraces([]Span{root, client, local, server, child})
+		assert.Equal(t, time.Duration(0), traces[0].Duplicates[0].Skew)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		assert := assert.New(t)
+		traces := AssembleTraces([]Span{root, client, local, server, child}, WithSkewCorrection())
+		trace := traces[0]
+
+		// centered within the client: 10ms + (40ms - 20ms)/2 = 20ms
+		assert.Equal(60*time.Millisecond, trace.Duplicates[0].Skew)
+		assert.Equal(assemblyEpoch.Add(20*time.Millisecond), trace.Duplicates[0].Span.StartTime)
+		assert.Equal(map[string]string{skewKey: "60ms"}, trace.Duplicates[0].Span.Attributes)
+		assert.Nil(server.Attributes)
+
+		nested := trace.Roots[0].Children[0].Children[0]
+		assert.Equal("child", nested.Span.Name)
+		assert.Equal(60*time.Millisecond, nested.Skew)
+		assert.Equal(assemblyEpoch.Add(25*time.Millisecond), nested.Span.StartTime)
+
+		assert.Equal(time.Duration(0), trace.Roots[0].Skew)
+		assert.Empty(trace.Roots[0].Span.Attributes)
+	})
+
+	t.Run("WithinClient", func(t *testing.T) {
+		inside := createAssemblySpan("server", "a", 1, 2, 15*time.Millisecond, 20*time.Millisecond)
+		inside.Host = "host-b"
+
+		traces := AssembleTraces([]Span{root, client, inside}, WithSkewCorrection())
+		assert.Equal(t, time.Duration(0), traces[0].Duplicates[0].Skew)
+	})
+}
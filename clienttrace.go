@@ -0,0 +1,126 @@
+package money
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client trace attribute keys. Durations are those of the phases of a request;
+// offsets count from the moment the request was handed to the transactor.
+const (
+	dnsDurationKey     = "dns-duration"
+	connectDurationKey = "connect-duration"
+	tlsDurationKey     = "tls-duration"
+	gotConnKey         = "got-conn-after"
+	connReusedKey      = "conn-reused"
+	firstByteKey       = "first-byte-after"
+)
+
+// clientTimingKeys are all keys a clientTiming may record
+var clientTimingKeys = map[string]bool{
+	dnsDurationKey: true, connectDurationKey: true, tlsDurationKey: true,
+	gotConnKey: true, connReusedKey: true, firstByteKey: true,
+}
+
+// clientTiming collects the timings of an outbound request through an httptrace.ClientTrace.
+// Hooks may be called concurrently, i.e. when dialing several addresses.
+type clientTiming struct {
+	clock Clock
+	start time.Time
+
+	m            sync.Mutex
+	dnsStart     time.Duration
+	connectStart time.Duration
+	tlsStart     time.Duration
+	attributes   map[string]string
+}
+
+func newClientTiming(clock Clock) *clientTiming {
+	return &clientTiming{
+		clock:      clock,
+		start:      clock.Start(),
+		attributes: make(map[string]string),
+	}
+}
+
+// since returns the offset of now from the start of the request
+func (c *clientTiming) since() time.Duration {
+	return c.clock.End(c.start)
+}
+
+func (c *clientTiming) set(k, v string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.attributes[k] = v
+}
+
+// mark records the offset at which a phase started
+func (c *clientTiming) mark(start *time.Duration) {
+	at := c.since()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	*start = at
+}
+
+// done records the duration of a phase which started at the offset start
+func (c *clientTiming) done(k string, start *time.Duration) {
+	at := c.since()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.attributes[k] = (at - *start).String()
+}
+
+func (c *clientTiming) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { c.mark(&c.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { c.done(dnsDurationKey, &c.dnsStart) },
+		ConnectStart:      func(string, string) { c.mark(&c.connectStart) },
+		ConnectDone:       func(string, string, error) { c.done(connectDurationKey, &c.connectStart) },
+		TLSHandshakeStart: func() { c.mark(&c.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { c.done(tlsDurationKey, &c.tlsStart) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.set(gotConnKey, c.since().String())
+			c.set(connReusedKey, strconv.FormatBool(info.Reused))
+		},
+		GotFirstResponseByte: func() { c.set(firstByteKey, c.since().String()) },
+	}
+}
+
+// timings returns a copy of the attributes recorded so far
+func (c *clientTiming) timings() map[string]string {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return mergeAttributes(nil, c.attributes)
+}
+
+// setTimings replaces the timings of any earlier outbound request on the span of t by
+// those of c, such that the span never mixes the timings of several requests
+func (t *HTTPTracker) setTimings(c *clientTiming) {
+	timings := c.timings()
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.done {
+		return
+	}
+
+	attributes := make(map[string]string, len(t.span.Attributes)+len(timings))
+	for k, v := range t.span.Attributes {
+		if !clientTimingKeys[k] {
+			attributes[k] = v
+		}
+	}
+
+	for k, v := range timings {
+		attributes[k] = v
+	}
+
+	t.span.Attributes = attributes
+}
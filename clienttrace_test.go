@@ -0,0 +1,109 @@
+package money
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecorateTransactorClientTrace(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	call := func(spanner *HTTPSpanner, client *http.Client) map[string]string {
+		var finished []Span
+		spanner.processors = []SpanProcessor{SpanProcessorFunc(func(s Span) { finished = append(finished, s) })}
+
+		tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 1}})
+		request, err := http.NewRequest("GET", server.URL, nil)
+		require.Nil(t, err)
+
+		resp, err := tracker.DecorateTransactor(client.Do)(request)
+		require.Nil(t, err)
+		resp.Body.Close()
+
+		tracker.Finish(Result{Name: "GET", Code: resp.StatusCode, Success: true})
+		return finished[0].Attributes
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Empty(t, call(NewHTTPSpanner(), server.Client()))
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		assert := assert.New(t)
+		var (
+			spanner = NewHTTPSpanner(WithClientTrace())
+			client  = &http.Client{Transport: server.Client().Transport.(*http.Transport).Clone()}
+		)
+
+		first := call(spanner, client)
+		for _, k := range []string{connectDurationKey, tlsDurationKey, gotConnKey, firstByteKey} {
+			d, err := time.ParseDuration(first[k])
+			assert.Nil(err, k)
+			assert.True(d >= 0, k)
+		}
+
+		assert.Equal("false", first[connReusedKey])
+		assert.NotContains(first, dnsDurationKey)
+
+		second := call(spanner, client)
+		assert.Equal("true", second[connReusedKey])
+	})
+}
+
+// steppingClock advances by step every time a duration is measured
+type steppingClock struct {
+	step    time.Duration
+	elapsed time.Duration
+}
+
+func (c *steppingClock) Start() time.Time { return stubClock{}.Start() }
+
+func (c *steppingClock) End(time.Time) time.Duration {
+	c.elapsed += c.step
+	return c.elapsed
+}
+
+func TestClientTiming(t *testing.T) {
+	assert := assert.New(t)
+	var clock = &steppingClock{step: 5 * time.Millisecond}
+
+	timing := newClientTiming(clock)
+	trace := timing.trace()
+
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.GotFirstResponseByte()
+
+	assert.Equal(map[string]string{dnsDurationKey: "5ms", firstByteKey: "15ms"}, timing.timings())
+}
+
+func TestHTTPTrackerSetTimings(t *testing.T) {
+	assert := assert.New(t)
+	tracker := NewHTTPSpanner().start(context.Background(), Span{
+		TC:         &TraceContext{TID: "abc", PID: 1, SID: 1},
+		Attributes: map[string]string{"route": "/devices"},
+	}, false)
+
+	first := newClientTiming(&steppingClock{step: time.Millisecond})
+	first.set(dnsDurationKey, "1ms")
+	first.set(connectDurationKey, "2ms")
+	first.set(connReusedKey, "false")
+	tracker.setTimings(first)
+
+	// the timings of a later request replace all of the earlier ones
+	second := newClientTiming(&steppingClock{step: time.Millisecond})
+	second.set(connReusedKey, "true")
+	tracker.setTimings(second)
+
+	assert.Equal(map[string]string{"route": "/devices", connReusedKey: "true"}, tracker.span.Attributes)
+}
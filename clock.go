@@ -0,0 +1,18 @@
+package money
+
+import "time"
+
+// Clock measures spans: Start marks the beginning of a span and End returns
+// the duration of a span which began at start.
+type Clock interface {
+	Start() time.Time
+	End(start time.Time) time.Duration
+}
+
+// systemClock is the default Clock. Times returned by time.Now carry a monotonic
+// reading, so durations are not affected by changes to the wall clock.
+type systemClock struct{}
+
+func (systemClock) Start() time.Time { return time.Now() }
+
+func (systemClock) End(start time.Time) time.Duration { return time.Since(start) }
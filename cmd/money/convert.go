@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// encoders are the output formats of convert, all provided by the library itself
+var encoders = map[string]func(io.Writer, []money.Span) error{
+	"jsonl":  money.EncodeJSONLines,
+	"csv":    money.EncodeCSV,
+	"zipkin": money.EncodeZipkin,
+	"otlp":   money.EncodeOTLP,
+	"chrome": money.EncodeChromeTrace,
+}
+
+func encoderNames() string {
+	var names = make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func convert(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	var (
+		fs     = flag.NewFlagSet("convert", flag.ContinueOnError)
+		input  = fs.String("from", inputAuto, "input format: auto, string, map or header")
+		output = fs.String("to", "jsonl", "output format: "+encoderNames())
+		file   = fs.String("o", "", "write to this file instead of stdout")
+	)
+
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: money convert [flags] [file...]")
+		fs.PrintDefaults()
+	}
+
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	encode, ok := encoders[*output]
+	if !ok {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	spans, err := readSpans(fs.Args(), *input, stdin, stderr)
+	if err != nil {
+		return
+	}
+
+	if *file != "" {
+		f, cerr := os.Create(*file)
+		if cerr != nil {
+			return cerr
+		}
+
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+
+		stdout = f
+	}
+
+	return encode(stdout, spans)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+func TestConvert(t *testing.T) {
+	var (
+		span   = testSpan("ServeHTTP", "scytale", "a", 1, 1, 0, 100*time.Millisecond, true)
+		header = "X-MoneySpans: " + span + ", " + testSpan("fanout", "scytale", "a", 1, 2, 0, time.Millisecond, true)
+	)
+
+	s, err := money.ParseSpan(span)
+	assert.Nil(t, err)
+	m, err := s.MapWith(money.SpecSchema)
+	assert.Nil(t, err)
+	mapLine, err := json.Marshal(m)
+	assert.Nil(t, err)
+
+	t.Run("InputFormats", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, stdout, _ := runMoney(span+"\n"+string(mapLine)+"\n"+header, "convert")
+		assert.Len(strings.Split(strings.TrimSpace(stdout), "\n"), 4)
+
+		_, stdout, _ = runMoney(span+"\n"+string(mapLine)+"\n"+header, "convert", "-from", "map")
+		assert.Equal(string(mapLine)+"\n", stdout)
+
+		_, stdout, _ = runMoney(span+"\n"+string(mapLine)+"\n"+header, "convert", "-from", "header")
+		assert.Len(strings.Split(strings.TrimSpace(stdout), "\n"), 2)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		code, stdout, _ := runMoney(span, "convert", "-to", "csv")
+		assert.Equal(t, 0, code)
+		assert.True(t, strings.HasPrefix(stdout, "trace-id,span-id,parent-id,span-name"))
+	})
+
+	t.Run("OutputFile", func(t *testing.T) {
+		assert := assert.New(t)
+		out := filepath.Join(t.TempDir(), "trace.json")
+
+		code, stdout, _ := runMoney(span, "convert", "-to", "zipkin", "-o", out)
+		assert.Equal(0, code)
+		assert.Empty(stdout)
+
+		b, err := os.ReadFile(out)
+		assert.Nil(err)
+		assert.Contains(string(b), `"name":"ServeHTTP"`)
+	})
+
+	t.Run("UnknownFormats", func(t *testing.T) {
+		code, _, stderr := runMoney(span, "convert", "-to", "xml")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `unknown output format "xml"`)
+
+		code, _, stderr = runMoney(span, "convert", "-from", "xml")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `unknown input format "xml"`)
+	})
+}
@@ -0,0 +1,193 @@
+// Command money inspects the span logs produced by golang-money.
+//
+// Usage:
+//
+//	money <command> [flags] [file...]
+//
+// Span lines are read from the given files or from stdin when none are given.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// command runs a single money subcommand
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"show":    {usage: "print spans as a call tree or waterfall per trace", run: show},
+	"convert": {usage: "convert spans into other trace formats", run: convert},
+	"stats":   {usage: "aggregate latency and error statistics per operation", run: stats},
+	"serve":   {usage: "run a local span collector", run: serve},
+}
+
+// Input formats of span lines
+const (
+	inputAuto   = "auto"
+	inputString = "string"
+	inputMap    = "map"
+	inputHeader = "header"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	c, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "money: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	if err := c.run(args[1:], stdin, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "money %s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage(w io.Writer) {
+	var names = make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: money <command> [flags] [file...]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// readSpans decodes all span lines found in the given files, or stdin if there are none.
+// Lines may carry a prefix (i.e. a log timestamp) before the span itself. Lines which
+// cannot be decoded are reported to stderr and skipped.
+func readSpans(files []string, input string, stdin io.Reader, stderr io.Writer) ([]money.Span, error) {
+	switch input {
+	case inputAuto, inputString, inputMap, inputHeader:
+	default:
+		return nil, fmt.Errorf("unknown input format %q", input)
+	}
+
+	if len(files) == 0 {
+		return scanSpans("stdin", input, stdin, stderr)
+	}
+
+	var spans []money.Span
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := scanSpans(name, input, f, stderr)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		spans = append(spans, s...)
+	}
+
+	return spans, nil
+}
+
+func scanSpans(name, input string, r io.Reader, stderr io.Writer) (spans []money.Span, err error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		line    int
+	)
+
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line++
+
+		s, err := decodeLine(input, scanner.Text())
+		if err != nil {
+			fmt.Fprintf(stderr, "%s:%d: %v\n", name, line, err)
+			continue
+		}
+
+		spans = append(spans, s...)
+	}
+
+	return spans, scanner.Err()
+}
+
+// decodeLine decodes the spans held in a single line of the given input format.
+// Lines without spans decode to none.
+func decodeLine(input, text string) ([]money.Span, error) {
+	var trimmed = strings.TrimSpace(text)
+
+	if input == inputAuto {
+		switch {
+		case strings.HasPrefix(trimmed, "{"):
+			input = inputMap
+		case strings.Contains(text, money.MoneySpansHeader+":"):
+			input = inputHeader
+		default:
+			input = inputString
+		}
+	}
+
+	switch input {
+	case inputMap:
+		if !strings.HasPrefix(trimmed, "{") {
+			return nil, nil
+		}
+
+		var m money.SpanMap
+		if err := json.Unmarshal([]byte(trimmed), &m); err != nil {
+			return nil, err
+		}
+
+		s, err := money.SpanFromMap(m)
+		if err != nil {
+			return nil, err
+		}
+
+		return []money.Span{s}, nil
+
+	case inputHeader:
+		i := strings.Index(text, money.MoneySpansHeader+":")
+		if i < 0 {
+			return nil, nil
+		}
+
+		return money.ParseSpans(text[i+len(money.MoneySpansHeader)+1:])
+	}
+
+	i := strings.Index(text, "span-name=")
+	if i < 0 {
+		return nil, nil
+	}
+
+	s, err := money.ParseSpan(text[i:])
+	if err != nil {
+		return nil, err
+	}
+
+	return []money.Span{s}, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+var testEpoch = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+func testSpan(name, app, tid string, pid, sid int64, start, duration time.Duration, success bool) string {
+	s := money.Span{
+		Name:      name,
+		AppName:   app,
+		TC:        &money.TraceContext{TID: tid, PID: pid, SID: sid},
+		Success:   success,
+		StartTime: testEpoch.Add(start),
+		Duration:  duration,
+	}
+
+	if !success {
+		s.Code = 500
+	}
+
+	return s.String()
+}
+
+func testLog() string {
+	return strings.Join([]string{
+		"2019/05/01 10:30:00 " + testSpan("ServeHTTP", "scytale", "a", 1, 1, 0, 100*time.Millisecond, true),
+		testSpan("fanout", "scytale", "a", 1, 2, 10*time.Millisecond, 50*time.Millisecond, true),
+		"an unrelated log line",
+		testSpan("ServeHTTP", "talaria", "b", 5, 5, 0, 5*time.Millisecond, false),
+		"span-name=broken;trace-id",
+	}, "\n")
+}
+
+func runMoney(stdin string, args ...string) (code int, stdout, stderr string) {
+	var o, e bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &o, &e)
+	return code, o.String(), e.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("NoCommand", func(t *testing.T) {
+		code, _, stderr := runMoney("")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, "usage: money")
+	})
+
+	t.Run("UnknownCommand", func(t *testing.T) {
+		code, _, stderr := runMoney("", "nope")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, `unknown command "nope"`)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		code, _, _ := runMoney("", "show", "does-not-exist.log")
+		assert.Equal(t, 1, code)
+	})
+}
+
+func TestShow(t *testing.T) {
+	t.Run("Tree", func(t *testing.T) {
+		assert := assert.New(t)
+		code, stdout, stderr := runMoney(testLog(), "show")
+		assert.Equal(0, code)
+		assert.Equal("trace-id=a\n"+
+			"  ServeHTTP [scytale] 100ms ok\n"+
+			"    fanout [scytale] 50ms ok\n"+
+			"trace-id=b\n"+
+			"  ServeHTTP [talaria] 5ms FAILED code=500\n", stdout)
+		assert.Contains(stderr, "stdin:5:")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, stdout, _ := runMoney(testLog(), "show", "-failed")
+		assert.NotContains(stdout, "trace-id=a")
+		assert.Contains(stdout, "trace-id=b")
+
+		_, stdout, _ = runMoney(testLog(), "show", "-min-duration", "60ms")
+		assert.Contains(stdout, "trace-id=a")
+		assert.NotContains(stdout, "trace-id=b")
+
+		_, stdout, _ = runMoney(testLog(), "show", "-app", "talaria", "-trace", "a")
+		assert.Empty(stdout)
+	})
+
+	t.Run("Waterfall", func(t *testing.T) {
+		assert := assert.New(t)
+		code, stdout, _ := runMoney(testLog(), "show", "-format", "waterfall", "-width", "10", "-trace", "a")
+		assert.Equal(0, code)
+		assert.Equal("trace-id=a\n"+
+			"  |==========| ServeHTTP [scytale] 100ms ok\n"+
+			"  | =====    |   fanout [scytale] 50ms ok\n", stdout)
+	})
+
+	t.Run("Skew", func(t *testing.T) {
+		var lines []string
+		for _, s := range []money.Span{
+			{Name: "root", AppName: "scytale", Host: "host-a", TC: &money.TraceContext{TID: "c", PID: 1, SID: 1},
+				Success: true, StartTime: testEpoch, Duration: 100 * time.Millisecond},
+			{Name: "client", AppName: "scytale", Host: "host-a", TC: &money.TraceContext{TID: "c", PID: 1, SID: 2},
+				Success: true, StartTime: testEpoch.Add(10 * time.Millisecond), Duration: 40 * time.Millisecond},
+			{Name: "server", AppName: "talaria", Host: "host-b", TC: &money.TraceContext{TID: "c", PID: 1, SID: 2},
+				Success: true, StartTime: testEpoch.Add(-40 * time.Millisecond), Duration: 20 * time.Millisecond},
+			{Name: "query", AppName: "talaria", Host: "host-b", TC: &money.TraceContext{TID: "c", PID: 2, SID: 3},
+				Success: true, StartTime: testEpoch.Add(-35 * time.Millisecond), Duration: 10 * time.Millisecond},
+		} {
+			lines = append(lines, s.String())
+		}
+
+		_, stdout, _ := runMoney(strings.Join(lines, "\n"), "show", "-skew")
+		assert.Equal(t, "trace-id=c duplicates=1\n"+
+			"  root [scytale] 100ms ok\n"+
+			"    client [scytale] 40ms ok\n"+
+			"    server [talaria] 20ms ok skew=60ms (duplicate)\n"+
+			"      query [talaria] 10ms ok skew=60ms\n", stdout)
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var lines []string
+		for _, s := range []money.Span{
+			{Name: "root", AppName: "scytale", TC: &money.TraceContext{TID: "d", PID: 1, SID: 1},
+				Success: true, StartTime: testEpoch, Duration: 100 * time.Millisecond},
+			{Name: "client", AppName: "scytale", TC: &money.TraceContext{TID: "d", PID: 1, SID: 2},
+				Success: true, StartTime: testEpoch.Add(10 * time.Millisecond), Duration: 40 * time.Millisecond},
+			{Name: "server", AppName: "talaria", TC: &money.TraceContext{TID: "d", PID: 1, SID: 2},
+				Success: true, StartTime: testEpoch.Add(15 * time.Millisecond), Duration: 30 * time.Millisecond},
+		} {
+			lines = append(lines, s.String())
+		}
+
+		code, stdout, _ := runMoney(strings.Join(lines, "\n"), "show", "-app", "talaria")
+		assert.Equal(0, code)
+		assert.Equal("trace-id=d duplicates=1\n"+
+			"  root [scytale] 100ms ok\n"+
+			"    client [scytale] 40ms ok\n"+
+			"    server [talaria] 30ms ok (duplicate)\n", stdout)
+
+		_, stdout, _ = runMoney(strings.Join(lines, "\n"), "show", "-format", "waterfall", "-width", "10")
+		assert.Contains(stdout, "  | ===      |   server [talaria] 30ms ok (duplicate)\n")
+	})
+
+	t.Run("BadFormat", func(t *testing.T) {
+		code, _, stderr := runMoney(testLog(), "show", "-format", "pie")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `unknown format "pie"`)
+	})
+}
+
+// statsLog returns ten query spans, 100ms apart, lasting 1ms to 10ms; the 3rd and 7th failed with 503
+func statsLog() string {
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		s := money.Span{
+			Name:      "query",
+			AppName:   "petasos",
+			TC:        &money.TraceContext{TID: "q", PID: 1, SID: int64(i)},
+			Success:   i != 3 && i != 7,
+			Code:      200,
+			StartTime: testEpoch.Add(time.Duration(i-1) * 100 * time.Millisecond),
+			Duration:  time.Duration(i) * time.Millisecond,
+		}
+
+		if !s.Success {
+			s.Code = 503
+		}
+
+		lines = append(lines, s.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func TestStats(t *testing.T) {
+	var window = []string{"-since", testEpoch.Format(time.RFC3339Nano), "-until", testEpoch.Add(time.Second).Format(time.RFC3339Nano)}
+
+	runJSON := func(t *testing.T, args ...string) (stats []operationStats) {
+		code, stdout, stderr := runMoney(statsLog(), append([]string{"stats", "-format", "json"}, args...)...)
+		assert.Equal(t, 0, code, stderr)
+		assert.Nil(t, json.Unmarshal([]byte(stdout), &stats))
+		return
+	}
+
+	t.Run("Percentiles", func(t *testing.T) {
+		assert := assert.New(t)
+		stats := runJSON(t, window...)
+
+		assert.Len(stats, 1)
+		s := stats[0]
+		assert.Equal("petasos", s.App)
+		assert.Equal("query", s.Span)
+		assert.Nil(s.Code)
+		assert.Equal(10, s.Count)
+		assert.Equal(2, s.Errors)
+		assert.Equal(0.2, s.ErrorRate)
+		assert.Equal("5ms", s.P50)
+		assert.Equal("9ms", s.P90)
+		assert.Equal("10ms", s.P99)
+		assert.Equal("10ms", s.Max)
+		assert.Equal(10.0, s.Throughput)
+	})
+
+	t.Run("ByCode", func(t *testing.T) {
+		assert := assert.New(t)
+		stats := runJSON(t, append(window, "-by-code")...)
+
+		assert.Len(stats, 2)
+		assert.Equal(200, *stats[0].Code)
+		assert.Equal(8, stats[0].Count)
+		assert.Equal(0, stats[0].Errors)
+		assert.Equal(503, *stats[1].Code)
+		assert.Equal(2, stats[1].Count)
+		assert.Equal(1.0, stats[1].ErrorRate)
+		assert.Equal("7ms", stats[1].Max)
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		assert := assert.New(t)
+		stats := runJSON(t, "-since", testEpoch.Add(500*time.Millisecond).Format(time.RFC3339Nano),
+			"-until", testEpoch.Add(900*time.Millisecond).Format(time.RFC3339Nano))
+
+		// spans starting at 500ms to 800ms, 4 spans over 400ms
+		assert.Len(stats, 1)
+		assert.Equal(4, stats[0].Count)
+		assert.Equal(1, stats[0].Errors)
+		assert.Equal("9ms", stats[0].Max)
+		assert.Equal(10.0, stats[0].Throughput)
+	})
+
+	t.Run("ImpliedWindow", func(t *testing.T) {
+		// without bounds, the window spans from the first start to the last end: 0 to 910ms
+		stats := runJSON(t)
+		assert.InDelta(t, 10/0.91, stats[0].Throughput, 1e-9)
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		assert := assert.New(t)
+		code, stdout, _ := runMoney(statsLog(), append([]string{"stats", "-by-code"}, window...)...)
+		assert.Equal(0, code)
+		assert.Equal("APP      SPAN   CODE  COUNT  ERRORS  P50  P90   P99   MAX   RATE/S\n"+
+			"petasos  query  200   8      0.0%    5ms  10ms  10ms  10ms  8.00\n"+
+			"petasos  query  503   2      100.0%  3ms  7ms   7ms   7ms   2.00\n", stdout)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		code, _, stderr := runMoney(statsLog(), "stats", "-format", "csv")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, `unknown format "csv"`)
+
+		code, _, _ = runMoney(statsLog(), "stats", "-since", "yesterday")
+		assert.Equal(t, 1, code)
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/xmidt-org/golang-money/collector"
+)
+
+// listenAndServe is swapped out by tests
+var listenAndServe = func(s *http.Server) error {
+	return s.ListenAndServe()
+}
+
+func serve(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var (
+		fs       = flag.NewFlagSet("serve", flag.ContinueOnError)
+		addr     = fs.String("addr", ":9411", "address to listen on")
+		capacity = fs.Int("capacity", collector.DefaultCapacity, "maximum number of spans kept in memory")
+		preload  = fs.String("from", inputAuto, "input format of the files to preload: auto, string, map or header")
+	)
+
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: money serve [flags] [file...]")
+		fmt.Fprintln(stderr, "\nSpans found in the given files are loaded before serving.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st := collector.NewStore(*capacity)
+	if fs.NArg() > 0 {
+		spans, err := readSpans(fs.Args(), *preload, stdin, stderr)
+		if err != nil {
+			return err
+		}
+
+		st.Add(spans...)
+	}
+
+	fmt.Fprintf(stdout, "collecting spans on %s (%d preloaded)\n", *addr, st.Len())
+	return listenAndServe(&http.Server{
+		Addr:    *addr,
+		Handler: collector.NewHandler(st),
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "spans.log")
+	assert.Nil(os.WriteFile(file, []byte(testLog()), 0600))
+
+	var server *http.Server
+	defer func(original func(*http.Server) error) { listenAndServe = original }(listenAndServe)
+	listenAndServe = func(s *http.Server) error {
+		server = s
+		return http.ErrServerClosed
+	}
+
+	code, stdout, stderr := runMoney("", "serve", "-addr", "localhost:0", "-capacity", "10", file)
+	assert.Equal(1, code)
+	assert.Equal("collecting spans on localhost:0 (3 preloaded)\n", stdout)
+	assert.Contains(stderr, http.ErrServerClosed.Error())
+
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/traces/a", nil))
+	assert.Equal(http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/spans", nil)
+	r.Header.Add("X-MoneySpans", testSpan("late", "scytale", "c", 1, 1, 0, time.Millisecond, true))
+	server.Handler.ServeHTTP(w, r)
+	assert.Equal(http.StatusAccepted, w.Code)
+}
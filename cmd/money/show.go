@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// spanFilter selects the traces worth printing
+type spanFilter struct {
+	tid         string
+	app         string
+	minDuration time.Duration
+	failed      bool
+}
+
+// match reports whether a single span satisfies all of the filter criteria
+func (f spanFilter) match(s money.Span) bool {
+	switch {
+	case f.tid != "" && s.TC.TID != f.tid:
+		return false
+	case f.app != "" && s.AppName != f.app:
+		return false
+	case s.Duration < f.minDuration:
+		return false
+	case f.failed && s.Success && s.Err == nil:
+		return false
+	}
+
+	return true
+}
+
+func (f *spanFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.tid, "trace", "", "only include the given trace-id")
+	fs.StringVar(&f.app, "app", "", "only include spans of the given app-name")
+	fs.DurationVar(&f.minDuration, "min-duration", 0, "only include spans lasting at least this long")
+	fs.BoolVar(&f.failed, "failed", false, "only include failed spans")
+}
+
+func show(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var (
+		fs     = flag.NewFlagSet("show", flag.ContinueOnError)
+		filter spanFilter
+		format = fs.String("format", "tree", "output format: tree or waterfall")
+		width  = fs.Int("width", 60, "width of the waterfall bars")
+		skew   = fs.Bool("skew", false, "correct clock skew between hosts")
+		input  = fs.String("from", inputAuto, "input format: auto, string, map or header")
+	)
+
+	fs.SetOutput(stderr)
+	filter.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: money show [flags] [file...]")
+		fmt.Fprintln(stderr, "\nPrints every trace holding at least one span which matches the filters.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var printer func(io.Writer, *money.Trace)
+	switch *format {
+	case "tree":
+		printer = printTree
+	case "waterfall":
+		if *width < 1 {
+			return errors.New("width must be positive")
+		}
+
+		printer = func(w io.Writer, t *money.Trace) { printWaterfall(w, t, *width) }
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	spans, err := readSpans(fs.Args(), *input, stdin, stderr)
+	if err != nil {
+		return err
+	}
+
+	var options []money.AssemblyOptions
+	if *skew {
+		options = append(options, money.WithSkewCorrection())
+	}
+
+	for _, t := range money.AssembleTraces(spans, options...) {
+		if matchTrace(t, filter) {
+			printer(stdout, t)
+		}
+	}
+
+	return nil
+}
+
+func matchTrace(t *money.Trace, filter spanFilter) (matched bool) {
+	walkTrace(t, func(_ int, n *money.TraceNode, _ bool) {
+		matched = matched || filter.match(n.Span)
+	})
+
+	return
+}
+
+// walkTrace visits all nodes of the trace depth first, roots before orphans.
+// Duplicates, such as the server span of a client span, are visited right after
+// the node which holds their span-id, at the same depth.
+func walkTrace(t *money.Trace, visit func(depth int, n *money.TraceNode, duplicate bool)) {
+	duplicates := make(map[int64][]*money.TraceNode, len(t.Duplicates))
+	for _, d := range t.Duplicates {
+		duplicates[d.Span.TC.SID] = append(duplicates[d.Span.TC.SID], d)
+	}
+
+	var walk func(int, *money.TraceNode)
+	walk = func(depth int, n *money.TraceNode) {
+		visit(depth, n, false)
+		for _, d := range duplicates[n.Span.TC.SID] {
+			visit(depth, d, true)
+		}
+
+		delete(duplicates, n.Span.TC.SID)
+		for _, c := range n.Children {
+			walk(depth+1, c)
+		}
+	}
+
+	for _, n := range t.Roots {
+		walk(0, n)
+	}
+
+	for _, n := range t.Orphans {
+		walk(0, n)
+	}
+}
+
+func describe(n *money.TraceNode) string {
+	var (
+		b strings.Builder
+		s = n.Span
+	)
+
+	fmt.Fprintf(&b, "%s [%s] %v", s.Name, s.AppName, s.Duration)
+
+	if s.Success {
+		b.WriteString(" ok")
+	} else {
+		b.WriteString(" FAILED")
+	}
+
+	if s.Code != 0 {
+		fmt.Fprintf(&b, " code=%d", s.Code)
+	}
+
+	if s.Err != nil {
+		fmt.Fprintf(&b, " err=%v", s.Err)
+	}
+
+	if n.Skew != 0 {
+		fmt.Fprintf(&b, " skew=%v", n.Skew)
+	}
+
+	return b.String()
+}
+
+func printHeader(w io.Writer, t *money.Trace) {
+	fmt.Fprintf(w, "trace-id=%s", t.TID)
+
+	if len(t.Orphans) > 0 {
+		fmt.Fprintf(w, " orphans=%d", len(t.Orphans))
+	}
+
+	if len(t.Duplicates) > 0 {
+		fmt.Fprintf(w, " duplicates=%d", len(t.Duplicates))
+	}
+
+	fmt.Fprintln(w)
+}
+
+func printTree(w io.Writer, t *money.Trace) {
+	printHeader(w, t)
+
+	walkTrace(t, func(depth int, n *money.TraceNode, duplicate bool) {
+		var note string
+		switch {
+		case duplicate:
+			note = " (duplicate)"
+		case depth == 0 && n.Span.TC.PID != 0 && n.Span.TC.PID != n.Span.TC.SID:
+			note = " (orphan)"
+		}
+
+		fmt.Fprintf(w, "  %s%s%s\n", strings.Repeat("  ", depth), describe(n), note)
+	})
+}
+
+func printWaterfall(w io.Writer, t *money.Trace, width int) {
+	var start, end time.Time
+
+	walkTrace(t, func(_ int, n *money.TraceNode, _ bool) {
+		if start.IsZero() || n.Span.StartTime.Before(start) {
+			start = n.Span.StartTime
+		}
+
+		if n.End().After(end) {
+			end = n.End()
+		}
+	})
+
+	printHeader(w, t)
+
+	total := end.Sub(start)
+	walkTrace(t, func(depth int, n *money.TraceNode, duplicate bool) {
+		var from, to = 0, width
+		if total > 0 {
+			from = int(int64(width) * int64(n.Span.StartTime.Sub(start)) / int64(total))
+			to = int(int64(width) * int64(n.End().Sub(start)) / int64(total))
+		}
+
+		if to <= from {
+			to = from + 1
+		}
+
+		if to > width {
+			to = width
+			if from >= width {
+				from = width - 1
+			}
+		}
+
+		bar := strings.Repeat(" ", from) + strings.Repeat("=", to-from) + strings.Repeat(" ", width-to)
+		var note string
+		if duplicate {
+			note = " (duplicate)"
+		}
+
+		fmt.Fprintf(w, "  |%s| %s%s%s\n", bar, strings.Repeat("  ", depth), describe(n), note)
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// operation identifies a group of spans for which statistics are computed
+type operation struct {
+	app  string
+	span string
+	code int
+}
+
+// operationStats are the aggregate numbers of a single operation
+type operationStats struct {
+	App  string `json:"app"`
+	Span string `json:"span"`
+	Code *int   `json:"code,omitempty"`
+
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	ErrorRate  float64 `json:"errorRate"`
+	P50        string  `json:"p50"`
+	P90        string  `json:"p90"`
+	P99        string  `json:"p99"`
+	Max        string  `json:"max"`
+	Throughput float64 `json:"throughput"`
+
+	durations []time.Duration
+}
+
+// percentile returns the nearest-rank percentile p of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func stats(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var (
+		fs     = flag.NewFlagSet("stats", flag.ContinueOnError)
+		input  = fs.String("from", inputAuto, "input format: auto, string, map or header")
+		format = fs.String("format", "table", "output format: table or json")
+		byCode = fs.Bool("by-code", false, "also group operations by response-code")
+		since  = fs.String("since", "", "only include spans starting at or after this RFC3339 time")
+		until  = fs.String("until", "", "only include spans starting before this RFC3339 time")
+	)
+
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: money stats [flags] [file...]")
+		fmt.Fprintln(stderr, "\nThroughput is measured in spans per second over the time window, which")
+		fmt.Fprintln(stderr, "defaults to the interval covered by the spans themselves.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	var from, to time.Time
+	for _, bound := range []struct {
+		value string
+		t     *time.Time
+	}{{*since, &from}, {*until, &to}} {
+		if bound.value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, bound.value)
+		if err != nil {
+			return err
+		}
+
+		*bound.t = t
+	}
+
+	spans, err := readSpans(fs.Args(), *input, stdin, stderr)
+	if err != nil {
+		return err
+	}
+
+	var (
+		groups = make(map[operation]*operationStats)
+		first  time.Time
+		last   time.Time
+	)
+
+	for _, s := range spans {
+		if (!from.IsZero() && s.StartTime.Before(from)) || (!to.IsZero() && !s.StartTime.Before(to)) {
+			continue
+		}
+
+		if first.IsZero() || s.StartTime.Before(first) {
+			first = s.StartTime
+		}
+
+		if end := s.StartTime.Add(s.Duration); end.After(last) {
+			last = end
+		}
+
+		op := operation{app: s.AppName, span: s.Name}
+		if *byCode {
+			op.code = s.Code
+		}
+
+		g, ok := groups[op]
+		if !ok {
+			g = &operationStats{App: op.app, Span: op.span}
+			if *byCode {
+				g.Code = &op.code
+			}
+
+			groups[op] = g
+		}
+
+		g.Count++
+		g.durations = append(g.durations, s.Duration)
+		if !s.Success || s.Err != nil {
+			g.Errors++
+		}
+	}
+
+	if !from.IsZero() {
+		first = from
+	}
+
+	if !to.IsZero() {
+		last = to
+	}
+
+	window := last.Sub(first)
+	results := make([]*operationStats, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.durations, func(i, j int) bool { return g.durations[i] < g.durations[j] })
+
+		g.ErrorRate = float64(g.Errors) / float64(g.Count)
+		g.P50 = percentile(g.durations, 50).String()
+		g.P90 = percentile(g.durations, 90).String()
+		g.P99 = percentile(g.durations, 99).String()
+		g.Max = g.durations[len(g.durations)-1].String()
+
+		if window > 0 {
+			g.Throughput = float64(g.Count) / window.Seconds()
+		}
+
+		results = append(results, g)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch {
+		case a.App != b.App:
+			return a.App < b.App
+		case a.Span != b.Span:
+			return a.Span < b.Span
+		case a.Code != nil && b.Code != nil:
+			return *a.Code < *b.Code
+		}
+
+		return false
+	})
+
+	if *format == "json" {
+		e := json.NewEncoder(stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "APP\tSPAN\t")
+	if *byCode {
+		fmt.Fprint(w, "CODE\t")
+	}
+
+	fmt.Fprintln(w, "COUNT\tERRORS\tP50\tP90\tP99\tMAX\tRATE/S")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t", r.App, r.Span)
+		if r.Code != nil {
+			fmt.Fprintf(w, "%d\t", *r.Code)
+		}
+
+		fmt.Fprintf(w, "%d\t%.1f%%\t%s\t%s\t%s\t%s\t%.2f\n",
+			r.Count, 100*r.ErrorRate, r.P50, r.P90, r.P99, r.Max, r.Throughput)
+	}
+
+	return w.Flush()
+}
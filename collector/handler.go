@@ -0,0 +1,252 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// Paths served by Handler
+const (
+	SpansPath  = "/spans"
+	TracesPath = "/traces/"
+)
+
+// DefaultMaxBodyBytes is the largest ingest request body accepted by a Handler
+const DefaultMaxBodyBytes = 4 << 20
+
+var errMethodNotAllowed = errors.New("method not allowed")
+
+// Handler exposes a Store over HTTP.
+//
+//	POST /spans           ingests spans, see Ingest
+//	GET  /spans?...       queries spans, see ParseQuery
+//	GET  /traces/{id}     returns all spans of a trace
+//
+// Spans are returned as a JSON array of maps keyed by money.SpecSchema.
+type Handler struct {
+	Store *Store
+
+	// MaxBodyBytes limits the size of ingest request bodies, DefaultMaxBodyBytes if unset
+	MaxBodyBytes int64
+}
+
+// NewHandler returns a Handler serving the given store
+func NewHandler(st *Store) *Handler {
+	return &Handler{Store: st}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == SpansPath && r.Method == http.MethodPost:
+		h.ingest(w, r)
+	case r.URL.Path == SpansPath && r.Method == http.MethodGet:
+		h.query(w, r)
+	case strings.HasPrefix(r.URL.Path, TracesPath) && r.Method == http.MethodGet:
+		h.trace(w, r)
+	case r.URL.Path == SpansPath || strings.HasPrefix(r.URL.Path, TracesPath):
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) ingest(w http.ResponseWriter, r *http.Request) {
+	var max int64 = DefaultMaxBodyBytes
+	if h.MaxBodyBytes > 0 {
+		max = h.MaxBodyBytes
+	}
+
+	spans, err := Ingest(r, http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	h.Store.Add(spans...)
+	writeJSON(w, http.StatusAccepted, map[string]int{"accepted": len(spans)})
+}
+
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	q, err := ParseQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeSpans(w, h.Store.Query(q))
+}
+
+func (h *Handler) trace(w http.ResponseWriter, r *http.Request) {
+	spans := h.Store.Trace(strings.TrimPrefix(r.URL.Path, TracesPath))
+	if len(spans) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeSpans(w, spans)
+}
+
+// Ingest decodes the spans carried by an ingest request:
+//   - every X-MoneySpans header of the request
+//   - for application/json bodies, a single span map or an array of them,
+//     keyed by either money.MapSchema
+//   - for any other body, one money.Span.String per line; lines may also be
+//     dumps of X-MoneySpans headers
+func Ingest(r *http.Request, body io.Reader) (spans []money.Span, err error) {
+	for _, v := range r.Header[http.CanonicalHeaderKey(money.MoneySpansHeader)] {
+		var s []money.Span
+		if s, err = money.ParseSpans(v); err != nil {
+			return nil, fmt.Errorf("%s header: %v", money.MoneySpansHeader, err)
+		}
+
+		spans = append(spans, s...)
+	}
+
+	var s []money.Span
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+		s, err = decodeJSON(body)
+	} else {
+		s, err = decodeLines(body)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(spans, s...), nil
+}
+
+func decodeJSON(body io.Reader) ([]money.Span, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var maps []money.SpanMap
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(raw, &maps); err != nil {
+			return nil, err
+		}
+	} else {
+		var m money.SpanMap
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+
+		maps = append(maps, m)
+	}
+
+	spans := make([]money.Span, 0, len(maps))
+	for i, m := range maps {
+		s, err := money.SpanFromMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("span %d: %v", i, err)
+		}
+
+		spans = append(spans, s)
+	}
+
+	return spans, nil
+}
+
+func decodeLines(body io.Reader) (spans []money.Span, err error) {
+	var (
+		scanner = bufio.NewScanner(body)
+		line    int
+	)
+
+	scanner.Buffer(make([]byte, 64*1024), DefaultMaxBodyBytes)
+
+	for scanner.Scan() {
+		line++
+
+		var s []money.Span
+		if s, err = money.ParseSpans(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		spans = append(spans, s...)
+	}
+
+	return spans, scanner.Err()
+}
+
+// ParseQuery builds a Query out of the URL parameters of a request:
+// trace-id, app-name, span-name, since and until (RFC3339), min-duration
+// (i.e. 250ms), failed (true or false) and limit.
+func ParseQuery(r *http.Request) (q Query, err error) {
+	var v = r.URL.Query()
+
+	q.TID = v.Get("trace-id")
+	q.AppName = v.Get("app-name")
+	q.Name = v.Get("span-name")
+
+	for _, bound := range []struct {
+		key string
+		t   *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if raw := v.Get(bound.key); raw != "" {
+			if *bound.t, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+				return Query{}, fmt.Errorf("%s: %v", bound.key, err)
+			}
+		}
+	}
+
+	if raw := v.Get("min-duration"); raw != "" {
+		if q.MinDuration, err = time.ParseDuration(raw); err != nil {
+			return Query{}, fmt.Errorf("min-duration: %v", err)
+		}
+	}
+
+	if raw := v.Get("failed"); raw != "" {
+		var failed bool
+		if failed, err = strconv.ParseBool(raw); err != nil {
+			return Query{}, fmt.Errorf("failed: %v", err)
+		}
+
+		q.Failed = &failed
+	}
+
+	if raw := v.Get("limit"); raw != "" {
+		if q.Limit, err = strconv.Atoi(raw); err != nil || q.Limit < 0 {
+			return Query{}, fmt.Errorf("limit: invalid value %q", raw)
+		}
+	}
+
+	return q, nil
+}
+
+func writeSpans(w http.ResponseWriter, spans []money.Span) {
+	maps := make([]money.SpanMap, 0, len(spans))
+	for _, s := range spans {
+		//stored spans always have a trace context
+		m, _ := s.MapWith(money.SpecSchema)
+		maps = append(maps, m)
+	}
+
+	writeJSON(w, http.StatusOK, maps)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
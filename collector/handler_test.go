@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+func serve(h http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeSpans(t *testing.T, w *httptest.ResponseRecorder) []money.Span {
+	var maps []money.SpanMap
+	if err := json.Unmarshal(w.Body.Bytes(), &maps); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make([]money.Span, 0, len(maps))
+	for _, m := range maps {
+		s, err := money.SpanFromMap(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spans = append(spans, s)
+	}
+
+	return spans
+}
+
+func TestHandlerIngest(t *testing.T) {
+	var (
+		one   = createSpan("a", 1, "scytale", "ServeHTTP", 0, 100*time.Millisecond)
+		two   = createSpan("a", 2, "scytale", "fanout", 0, 50*time.Millisecond)
+		three = createSpan("b", 3, "talaria", "ServeHTTP", 0, 10*time.Millisecond)
+	)
+
+	t.Run("Lines", func(t *testing.T) {
+		assert := assert.New(t)
+		h := NewHandler(NewStore(10))
+
+		w := serve(h, "POST", "/spans", "text/plain", one.String()+"\nX-MoneySpans: "+two.String()+","+three.String()+"\n\n")
+		assert.Equal(http.StatusAccepted, w.Code)
+		assert.JSONEq(`{"accepted": 3}`, w.Body.String())
+		assert.Equal(3, h.Store.Len())
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		assert := assert.New(t)
+		h := NewHandler(NewStore(10))
+
+		r := httptest.NewRequest("POST", "/spans", nil)
+		r.Header.Add(money.MoneySpansHeader, one.String())
+		r.Header.Add(money.MoneySpansHeader, two.String())
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(http.StatusAccepted, w.Code)
+		assert.Equal(2, h.Store.Len())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		assert := assert.New(t)
+		h := NewHandler(NewStore(10))
+
+		m1, _ := one.MapWith(money.SpecSchema)
+		m2, _ := two.Map()
+		array, _ := json.Marshal([]money.SpanMap{m1, m2})
+		single, _ := json.Marshal(m1)
+
+		w := serve(h, "POST", "/spans", "application/json", string(array))
+		assert.Equal(http.StatusAccepted, w.Code)
+
+		w = serve(h, "POST", "/spans", "application/json; charset=utf-8", string(single))
+		assert.Equal(http.StatusAccepted, w.Code)
+		assert.Equal(3, h.Store.Len())
+	})
+
+	t.Run("BadRequests", func(t *testing.T) {
+		h := NewHandler(NewStore(10))
+
+		assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/spans", "", "span-name=x;trace-id").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/spans", "application/json", "{").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/spans", "application/json", `[{"span-name": "x"}]`).Code)
+
+		h.MaxBodyBytes = 10
+		assert.Equal(t, http.StatusBadRequest, serve(h, "POST", "/spans", "", one.String()).Code)
+	})
+}
+
+func TestHandlerQuery(t *testing.T) {
+	h := NewHandler(NewStore(10))
+	h.Store.Add(
+		createSpan("a", 1, "scytale", "ServeHTTP", 0, 100*time.Millisecond),
+		createSpan("a", 2, "scytale", "fanout", time.Second, 50*time.Millisecond),
+		createSpan("b", 3, "talaria", "ServeHTTP", 2*time.Second, 10*time.Millisecond),
+	)
+
+	t.Run("Spans", func(t *testing.T) {
+		assert := assert.New(t)
+		w := serve(h, "GET", "/spans?app-name=scytale&min-duration=60ms&failed=false&since=2019-05-01T10:30:00Z", "", "")
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Equal("application/json", w.Header().Get("Content-Type"))
+		assert.Equal([]string{"a/1"}, spanIDs(decodeSpans(t, w)))
+
+		w = serve(h, "GET", "/spans?limit=1&span-name=ServeHTTP&until=2019-05-01T10:31:00Z", "", "")
+		assert.Equal([]string{"a/1"}, spanIDs(decodeSpans(t, w)))
+	})
+
+	t.Run("Trace", func(t *testing.T) {
+		assert := assert.New(t)
+		w := serve(h, "GET", "/traces/a", "", "")
+		assert.Equal(http.StatusOK, w.Code)
+		assert.Equal([]string{"a/1", "a/2"}, spanIDs(decodeSpans(t, w)))
+
+		assert.Equal(http.StatusNotFound, serve(h, "GET", "/traces/z", "", "").Code)
+	})
+
+	t.Run("BadQueries", func(t *testing.T) {
+		for _, query := range []string{"since=yesterday", "until=tomorrow", "min-duration=long", "failed=maybe", "limit=-1"} {
+			assert.Equal(t, http.StatusBadRequest, serve(h, "GET", "/spans?"+query, "", "").Code, query)
+		}
+	})
+
+	t.Run("Routing", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(h, "DELETE", "/spans", "", "").Code)
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(h, "POST", "/traces/a", "", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(h, "GET", "/", "", "").Code)
+	})
+}
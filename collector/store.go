@@ -0,0 +1,188 @@
+// Package collector receives golang-money spans over HTTP and keeps them in
+// memory so they can be queried during development and in integration tests.
+package collector
+
+import (
+	"sync"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// DefaultCapacity is the number of spans kept by a Store when none is given
+const DefaultCapacity = 10000
+
+// Query selects spans from a Store. Zero values match everything.
+type Query struct {
+	TID         string
+	AppName     string
+	Name        string
+	Since       time.Time //inclusive lower bound of the span start time
+	Until       time.Time //exclusive upper bound of the span start time
+	MinDuration time.Duration
+
+	// Failed, if set, only matches spans whose failure status is the given one.
+	// A span fails when it is not successful or carries an error.
+	Failed *bool
+
+	// Limit caps the number of returned spans, zero means no limit
+	Limit int
+}
+
+// Match reports whether the span satisfies the query, ignoring its Limit
+func (q Query) Match(s money.Span) bool {
+	switch {
+	case q.TID != "" && (s.TC == nil || s.TC.TID != q.TID):
+		return false
+	case q.AppName != "" && s.AppName != q.AppName:
+		return false
+	case q.Name != "" && s.Name != q.Name:
+		return false
+	case !q.Since.IsZero() && s.StartTime.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !s.StartTime.Before(q.Until):
+		return false
+	case s.Duration < q.MinDuration:
+		return false
+	case q.Failed != nil && *q.Failed != (!s.Success || s.Err != nil):
+		return false
+	}
+
+	return true
+}
+
+// Store is a bounded ring buffer of spans indexed by trace-id.
+// Once full, the oldest spans are overwritten. It is safe for concurrent use.
+type Store struct {
+	m     sync.RWMutex
+	spans []money.Span
+
+	//next is the sequence number of the next span to be added
+	//the span with sequence number n lives at n % len(spans)
+	next uint64
+
+	//traces maps trace-ids to the sequence numbers of their spans, oldest first
+	traces map[string][]uint64
+}
+
+// NewStore returns a store which keeps at most capacity spans.
+// DefaultCapacity is used for non-positive values.
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Store{
+		spans:  make([]money.Span, capacity),
+		traces: make(map[string][]uint64),
+	}
+}
+
+// Add stores the given spans. Spans without a trace context are dropped.
+func (st *Store) Add(spans ...money.Span) {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	for _, s := range spans {
+		if s.TC == nil {
+			continue
+		}
+
+		slot := st.next % uint64(len(st.spans))
+		if st.next >= uint64(len(st.spans)) {
+			st.evict(slot)
+		}
+
+		st.spans[slot] = s
+		st.traces[s.TC.TID] = append(st.traces[s.TC.TID], st.next)
+		st.next++
+	}
+}
+
+// evict removes the span currently held at slot from the trace index
+func (st *Store) evict(slot uint64) {
+	tid := st.spans[slot].TC.TID
+
+	//the evicted span is always the oldest of its trace
+	if seqs := st.traces[tid][1:]; len(seqs) > 0 {
+		st.traces[tid] = seqs
+	} else {
+		delete(st.traces, tid)
+	}
+}
+
+// Len returns the number of spans currently held
+func (st *Store) Len() int {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	if st.next < uint64(len(st.spans)) {
+		return int(st.next)
+	}
+
+	return len(st.spans)
+}
+
+// Trace returns all spans held for the given trace-id, oldest first
+func (st *Store) Trace(tid string) []money.Span {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	seqs := st.traces[tid]
+	spans := make([]money.Span, 0, len(seqs))
+	for _, seq := range seqs {
+		spans = append(spans, st.spans[seq%uint64(len(st.spans))])
+	}
+
+	return spans
+}
+
+// Query returns the spans matching q, oldest first
+func (st *Store) Query(q Query) []money.Span {
+	if q.TID != "" {
+		return limit(filter(st.Trace(q.TID), q), q.Limit)
+	}
+
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	var (
+		capacity = uint64(len(st.spans))
+		oldest   uint64
+		spans    []money.Span
+	)
+
+	if st.next > capacity {
+		oldest = st.next - capacity
+	}
+
+	for seq := oldest; seq < st.next; seq++ {
+		if s := st.spans[seq%capacity]; q.Match(s) {
+			spans = append(spans, s)
+			if q.Limit > 0 && len(spans) == q.Limit {
+				break
+			}
+		}
+	}
+
+	return spans
+}
+
+func filter(spans []money.Span, q Query) []money.Span {
+	var matched = spans[:0]
+	for _, s := range spans {
+		if q.Match(s) {
+			matched = append(matched, s)
+		}
+	}
+
+	return matched
+}
+
+func limit(spans []money.Span, n int) []money.Span {
+	if n > 0 && len(spans) > n {
+		return spans[:n]
+	}
+
+	return spans
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+var testEpoch = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+func createSpan(tid string, sid int64, app, name string, start, duration time.Duration) money.Span {
+	return money.Span{
+		Name:      name,
+		AppName:   app,
+		TC:        &money.TraceContext{TID: tid, PID: 1, SID: sid},
+		Success:   true,
+		StartTime: testEpoch.Add(start),
+		Duration:  duration,
+	}
+}
+
+func spanIDs(spans []money.Span) (ids []string) {
+	for _, s := range spans {
+		ids = append(ids, fmt.Sprintf("%s/%d", s.TC.TID, s.TC.SID))
+	}
+	return
+}
+
+func TestStoreRingBuffer(t *testing.T) {
+	assert := assert.New(t)
+	st := NewStore(3)
+
+	st.Add(createSpan("a", 1, "app", "one", 0, 0), money.Span{Name: "no-trace-context"})
+	st.Add(createSpan("b", 2, "app", "two", 0, 0), createSpan("a", 3, "app", "three", 0, 0))
+	assert.Equal(3, st.Len())
+
+	st.Add(createSpan("c", 4, "app", "four", 0, 0), createSpan("a", 5, "app", "five", 0, 0))
+	assert.Equal(3, st.Len())
+
+	assert.Equal([]string{"a/3", "a/5"}, spanIDs(st.Trace("a")))
+	assert.Empty(st.Trace("b"))
+	assert.Equal([]string{"a/3", "c/4", "a/5"}, spanIDs(st.Query(Query{})))
+}
+
+func TestStoreDefaultCapacity(t *testing.T) {
+	assert.Len(t, NewStore(0).spans, DefaultCapacity)
+}
+
+func TestStoreQuery(t *testing.T) {
+	var (
+		st     = NewStore(10)
+		failed = createSpan("b", 3, "talaria", "fanout", 2*time.Second, 20*time.Millisecond)
+		erred  = createSpan("c", 4, "scytale", "fanout", 3*time.Second, time.Millisecond)
+	)
+
+	failed.Success = false
+	erred.Err = errors.New("unavailable")
+
+	st.Add(
+		createSpan("a", 1, "scytale", "ServeHTTP", 0, 100*time.Millisecond),
+		createSpan("a", 2, "scytale", "fanout", time.Second, 50*time.Millisecond),
+		failed,
+		erred,
+	)
+
+	var yes, no = true, false
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"All", Query{}, []string{"a/1", "a/2", "b/3", "c/4"}},
+		{"Trace", Query{TID: "a", Name: "fanout"}, []string{"a/2"}},
+		{"App", Query{AppName: "scytale"}, []string{"a/1", "a/2", "c/4"}},
+		{"Name", Query{Name: "fanout"}, []string{"a/2", "b/3", "c/4"}},
+		{"TimeRange", Query{Since: testEpoch.Add(time.Second), Until: testEpoch.Add(3 * time.Second)}, []string{"a/2", "b/3"}},
+		{"MinDuration", Query{MinDuration: 50 * time.Millisecond}, []string{"a/1", "a/2"}},
+		{"Failed", Query{Failed: &yes}, []string{"b/3", "c/4"}},
+		{"Succeeded", Query{Failed: &no}, []string{"a/1", "a/2"}},
+		{"Limit", Query{Limit: 2}, []string{"a/1", "a/2"}},
+		{"TraceLimit", Query{TID: "a", Limit: 1}, []string{"a/1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, spanIDs(st.Query(test.query)))
+		})
+	}
+}
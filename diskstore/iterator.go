@@ -0,0 +1,118 @@
+package diskstore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// Iterator walks over the spans of a Store within a time range.
+//
+//	it := st.Scan(from, to)
+//	defer it.Close()
+//	for it.Next() {
+//		use(it.Span())
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iterator struct {
+	from, to time.Time
+	segments []*segment
+
+	file   *os.File
+	reader *bufio.Reader
+
+	//remaining is the number of bytes of the current segment left to read
+	remaining int64
+
+	span money.Span
+	err  error
+}
+
+// Next advances to the next span in range, returning false once there are no
+// more spans or an error occurred
+func (it *Iterator) Next() bool {
+	for it.err == nil {
+		if it.reader == nil && !it.open() {
+			return false
+		}
+
+		if it.remaining <= 0 {
+			it.closeSegment()
+			continue
+		}
+
+		s, n, err := readRecord(it.reader)
+		if errors.Is(err, errCorruptRecord) || errors.Is(err, errUndecodableRecord) {
+			it.remaining -= n
+			continue
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errTornRecord
+			}
+
+			it.err = err
+			return false
+		}
+
+		it.remaining -= n
+		if (it.from.IsZero() || !s.StartTime.Before(it.from)) && (it.to.IsZero() || s.StartTime.Before(it.to)) {
+			it.span = s
+			return true
+		}
+	}
+
+	return false
+}
+
+// open opens the next segment, returning false if there is none left or it failed.
+// Segments removed by retention since the call to Scan are skipped.
+func (it *Iterator) open() bool {
+	for len(it.segments) > 0 {
+		sg := it.segments[0]
+		it.segments = it.segments[1:]
+
+		f, err := os.Open(sg.path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.file, it.reader, it.remaining = f, bufio.NewReader(f), sg.size
+		return true
+	}
+
+	return false
+}
+
+func (it *Iterator) closeSegment() {
+	if it.file != nil {
+		it.file.Close()
+	}
+
+	it.file, it.reader = nil, nil
+}
+
+// Span returns the span the iterator is positioned at
+func (it *Iterator) Span() money.Span {
+	return it.span
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close releases the resources held by the iterator
+func (it *Iterator) Close() error {
+	it.closeSegment()
+	it.segments = nil
+	return nil
+}
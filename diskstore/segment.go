@@ -0,0 +1,212 @@
+package diskstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// Record layout: a 4 byte big endian payload length, the 4 byte CRC-32 (IEEE) of
+// the payload and the payload itself, which is the JSON object of the span's
+// money.SpecSchema map. Unlike money.Span.String, JSON does not lose spans whose
+// values contain the delimiters of the string encoding.
+const (
+	recordHeaderSize = 8
+	maxRecordSize    = 1 << 20
+	segmentExt       = ".seg"
+)
+
+// ErrSpanTooLarge is returned by Append for spans which do not fit into a record.
+// The other spans are appended nonetheless.
+var ErrSpanTooLarge = errors.New("diskstore: span exceeds the maximum record size")
+
+// Record errors. A torn record ends the valid part of a segment. A corrupt record fails
+// its checksum and an undecodable record holds no span, but both are complete such that
+// the records after them remain readable.
+var (
+	errTornRecord        = errors.New("torn record")
+	errCorruptRecord     = errors.New("corrupt record")
+	errUndecodableRecord = errors.New("undecodable record")
+)
+
+// segment is a single append-only file of span records
+type segment struct {
+	id   uint64
+	path string
+	size int64
+
+	//first and last are the earliest and latest start times of the spans in the segment
+	first, last time.Time
+}
+
+func (sg *segment) observe(s money.Span) {
+	if sg.first.IsZero() || s.StartTime.Before(sg.first) {
+		sg.first = s.StartTime
+	}
+
+	if s.StartTime.After(sg.last) {
+		sg.last = s.StartTime
+	}
+}
+
+// overlaps reports whether spans starting within [from, to) may be in the segment.
+// Zero bounds are open.
+func (sg *segment) overlaps(from, to time.Time) bool {
+	if sg.size == 0 {
+		return false
+	}
+
+	return (from.IsZero() || !sg.last.Before(from)) && (to.IsZero() || sg.first.Before(to))
+}
+
+func segmentPath(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", id, segmentExt))
+}
+
+// listSegments returns the ids of all segment files in dir, in ascending order
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func encodeRecord(s money.Span) ([]byte, error) {
+	m, err := s.MapWith(money.SpecSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) > maxRecordSize {
+		return nil, ErrSpanTooLarge
+	}
+
+	record := make([]byte, recordHeaderSize+len(payload))
+
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE([]byte(payload)))
+	copy(record[recordHeaderSize:], payload)
+
+	return record, nil
+}
+
+// readRecord reads the next record. It returns io.EOF at a clean end of input
+// and errTornRecord when the record is incomplete or its length is out of bounds.
+// A complete record which fails its checksum or whose payload is no span yields
+// errCorruptRecord or errUndecodableRecord along with the size of the record,
+// so the caller may skip it.
+func readRecord(r io.Reader) (s money.Span, n int64, err error) {
+	var header [recordHeaderSize]byte
+
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = errTornRecord
+		}
+
+		return
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return s, 0, errTornRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return s, 0, errTornRecord
+	}
+
+	n = recordHeaderSize + int64(size)
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return s, n, errCorruptRecord
+	}
+
+	var m money.SpanMap
+	if err = json.Unmarshal(payload, &m); err != nil {
+		return s, n, errUndecodableRecord
+	}
+
+	if s, err = money.SpanFromMap(m); err != nil {
+		return s, n, errUndecodableRecord
+	}
+
+	return s, n, nil
+}
+
+// scanSegment reads every valid record of the segment file at path, calling visit with
+// its offset. Corrupt and undecodable records are skipped unless the segment ends with
+// them. It returns the size of the valid prefix of the file, which is smaller than the
+// file itself when it ends with a torn or corrupt record.
+func scanSegment(path string, visit func(offset int64, s money.Span)) (valid int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		s, n, err := readRecord(r)
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, errTornRecord):
+			return valid, nil
+		case errors.Is(err, errCorruptRecord):
+			//a crash may leave the last record with a bad checksum
+			if valid+n >= info.Size() {
+				return valid, nil
+			}
+
+			valid += n
+			continue
+		case errors.Is(err, errUndecodableRecord):
+			valid += n
+			continue
+		case err != nil:
+			return valid, err
+		}
+
+		visit(valid, s)
+		valid += n
+	}
+}
@@ -0,0 +1,406 @@
+// Package diskstore keeps golang-money spans on local disk in append-only segment
+// files, without the need of an external database.
+//
+// Spans are appended to the newest segment until it reaches its size limit, after
+// which a new segment is started. Retention removes whole segments, either when all
+// of their spans are older than the maximum age or when the store outgrows its
+// maximum size. A segment torn by a crash is truncated to its last complete record
+// when the store is opened, while corrupt records within a segment are skipped.
+package diskstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// Defaults for the store limits
+const (
+	DefaultMaxSegmentBytes = 64 << 20
+)
+
+// ErrClosed is returned by operations on a closed store
+var ErrClosed = errors.New("diskstore: store is closed")
+
+// Options configure a Store
+type Options func(*Store)
+
+// WithMaxSegmentBytes sets the size at which a segment is closed and a new one started
+func WithMaxSegmentBytes(n int64) Options {
+	return func(st *Store) {
+		st.maxSegmentBytes = n
+	}
+}
+
+// WithMaxAge removes segments once their most recent span started more than d ago.
+// Zero disables time-based retention.
+func WithMaxAge(d time.Duration) Options {
+	return func(st *Store) {
+		st.maxAge = d
+	}
+}
+
+// WithMaxBytes removes the oldest segments while the store is larger than n bytes.
+// Zero disables size-based retention. The segment being written is never removed.
+func WithMaxBytes(n int64) Options {
+	return func(st *Store) {
+		st.maxBytes = n
+	}
+}
+
+// location addresses a single record
+type location struct {
+	segment uint64
+	offset  int64
+}
+
+// Store is a durable span store. It is safe for concurrent use.
+type Store struct {
+	dir             string
+	maxSegmentBytes int64
+	maxAge          time.Duration
+	maxBytes        int64
+
+	m        sync.RWMutex
+	segments []*segment //oldest first, the last one is active
+	active   *os.File
+	traces   map[string][]location
+	closed   bool
+
+	now func() time.Time
+}
+
+// Open opens the store held in dir, creating it if needed. Existing segments are
+// scanned to rebuild the trace-id index; torn records at their end are truncated.
+func Open(dir string, options ...Options) (*Store, error) {
+	st := &Store{
+		dir:             dir,
+		maxSegmentBytes: DefaultMaxSegmentBytes,
+		traces:          make(map[string][]location),
+		now:             time.Now,
+	}
+
+	for _, o := range options {
+		o(st)
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	ids, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err = st.recover(id); err != nil {
+			return nil, err
+		}
+	}
+
+	//appending continues in the last segment unless it is full
+	if n := len(st.segments); n > 0 && st.segments[n-1].size < st.maxSegmentBytes {
+		err = st.reopen(st.segments[n-1])
+	} else {
+		var next uint64 = 1
+		if n > 0 {
+			next = st.segments[n-1].id + 1
+		}
+
+		err = st.roll(next)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = st.retain(st.now()); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// recover indexes the segment with the given id and truncates any torn tail
+func (st *Store) recover(id uint64) error {
+	sg := &segment{id: id, path: segmentPath(st.dir, id)}
+
+	valid, err := scanSegment(sg.path, func(offset int64, s money.Span) {
+		sg.observe(s)
+		st.traces[s.TC.TID] = append(st.traces[s.TC.TID], location{segment: id, offset: offset})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(sg.path)
+	if err != nil {
+		return err
+	}
+
+	if info.Size() > valid {
+		if err = os.Truncate(sg.path, valid); err != nil {
+			return err
+		}
+	}
+
+	sg.size = valid
+	st.segments = append(st.segments, sg)
+	return nil
+}
+
+// roll closes the active segment, if any, and starts the segment with the given id
+func (st *Store) roll(id uint64) error {
+	if st.active != nil {
+		if err := st.active.Close(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(segmentPath(st.dir, id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	st.active = f
+	st.segments = append(st.segments, &segment{id: id, path: f.Name()})
+	return nil
+}
+
+// reopen makes the existing segment the active one
+func (st *Store) reopen(sg *segment) error {
+	f, err := os.OpenFile(sg.path, os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	st.active = f
+	return nil
+}
+
+// Append appends the spans to the store. Spans without a trace context are dropped,
+// spans too large for a record are skipped and reported by ErrSpanTooLarge.
+// The spans are written but not synced, Sync flushes them to stable storage.
+func (st *Store) Append(spans ...money.Span) error {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	if st.closed {
+		return ErrClosed
+	}
+
+	var tooLarge bool
+	for _, s := range spans {
+		if s.TC == nil {
+			continue
+		}
+
+		record, err := encodeRecord(s)
+		if errors.Is(err, ErrSpanTooLarge) {
+			tooLarge = true
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		sg := st.segments[len(st.segments)-1]
+
+		if sg.size > 0 && sg.size+int64(len(record)) > st.maxSegmentBytes {
+			if err := st.roll(sg.id + 1); err != nil {
+				return err
+			}
+
+			sg = st.segments[len(st.segments)-1]
+		}
+
+		if _, err = st.active.Write(record); err != nil {
+			//drop the part of the record which made it into the segment
+			if terr := st.active.Truncate(sg.size); terr != nil {
+				return fmt.Errorf("%w; truncating the segment: %v", err, terr)
+			}
+
+			return err
+		}
+
+		st.traces[s.TC.TID] = append(st.traces[s.TC.TID], location{segment: sg.id, offset: sg.size})
+		sg.size += int64(len(record))
+		sg.observe(s)
+	}
+
+	if err := st.retain(st.now()); err != nil {
+		return err
+	}
+
+	if tooLarge {
+		return ErrSpanTooLarge
+	}
+
+	return nil
+}
+
+// Sync flushes the active segment to stable storage
+func (st *Store) Sync() error {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	if st.closed {
+		return ErrClosed
+	}
+
+	return st.active.Sync()
+}
+
+// Retain applies the retention limits as of now. It also runs on Open and after every Append.
+func (st *Store) Retain(now time.Time) error {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	if st.closed {
+		return ErrClosed
+	}
+
+	return st.retain(now)
+}
+
+func (st *Store) retain(now time.Time) error {
+	var total int64
+	for _, sg := range st.segments {
+		total += sg.size
+	}
+
+	for len(st.segments) > 1 {
+		oldest := st.segments[0]
+		expired := st.maxAge > 0 && oldest.last.Add(st.maxAge).Before(now)
+		oversized := st.maxBytes > 0 && total > st.maxBytes
+
+		if !expired && !oversized {
+			break
+		}
+
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		total -= oldest.size
+		st.segments = st.segments[1:]
+		st.unindex(oldest.id)
+	}
+
+	return nil
+}
+
+// unindex drops all locations within the given segment from the trace-id index
+func (st *Store) unindex(id uint64) {
+	for tid, locations := range st.traces {
+		kept := locations[:0]
+		for _, l := range locations {
+			if l.segment != id {
+				kept = append(kept, l)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(st.traces, tid)
+		} else {
+			st.traces[tid] = kept
+		}
+	}
+}
+
+// Size returns the total size in bytes of all segments
+func (st *Store) Size() (total int64) {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	for _, sg := range st.segments {
+		total += sg.size
+	}
+
+	return
+}
+
+// Trace returns all stored spans of the given trace-id in the order they were appended
+func (st *Store) Trace(tid string) ([]money.Span, error) {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	if st.closed {
+		return nil, ErrClosed
+	}
+
+	var (
+		locations = st.traces[tid]
+		spans     = make([]money.Span, 0, len(locations))
+		files     = make(map[uint64]*os.File)
+	)
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, l := range locations {
+		f, ok := files[l.segment]
+		if !ok {
+			var err error
+			if f, err = os.Open(segmentPath(st.dir, l.segment)); err != nil {
+				return nil, err
+			}
+
+			files[l.segment] = f
+		}
+
+		s, _, err := readRecord(io.NewSectionReader(f, l.offset, maxRecordSize+recordHeaderSize))
+		if err != nil {
+			return nil, err
+		}
+
+		spans = append(spans, s)
+	}
+
+	return spans, nil
+}
+
+// Scan returns an iterator over the spans which started within [from, to), in the order
+// they were appended. Zero bounds are open. The iterator sees the spans appended before
+// the call to Scan.
+func (st *Store) Scan(from, to time.Time) *Iterator {
+	st.m.RLock()
+	defer st.m.RUnlock()
+
+	it := &Iterator{from: from, to: to}
+	if st.closed {
+		it.err = ErrClosed
+		return it
+	}
+
+	for _, sg := range st.segments {
+		if sg.overlaps(from, to) {
+			snapshot := *sg
+			it.segments = append(it.segments, &snapshot)
+		}
+	}
+
+	return it
+}
+
+// Close closes the active segment. The store cannot be used afterwards.
+func (st *Store) Close() error {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	if st.closed {
+		return ErrClosed
+	}
+
+	st.closed = true
+	return st.active.Close()
+}
@@ -0,0 +1,340 @@
+package diskstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	money "github.com/xmidt-org/golang-money"
+)
+
+var testEpoch = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+func createSpan(tid string, sid int64, start time.Duration) money.Span {
+	return money.Span{
+		Name:      "test-span",
+		AppName:   "test-app",
+		TC:        &money.TraceContext{TID: tid, PID: 1, SID: sid},
+		Success:   true,
+		StartTime: testEpoch.Add(start),
+		Duration:  time.Millisecond,
+	}
+}
+
+func encode(t *testing.T, s money.Span) []byte {
+	record, err := encodeRecord(s)
+	require.Nil(t, err)
+	return record
+}
+
+func spanIDs(spans []money.Span) (ids []string) {
+	for _, s := range spans {
+		ids = append(ids, fmt.Sprintf("%s/%d", s.TC.TID, s.TC.SID))
+	}
+	return
+}
+
+func scanAll(t *testing.T, st *Store, from, to time.Time) []money.Span {
+	it := st.Scan(from, to)
+	defer it.Close()
+
+	var spans []money.Span
+	for it.Next() {
+		spans = append(spans, it.Span())
+	}
+
+	require.Nil(t, it.Err())
+	return spans
+}
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	record := int64(len(encode(t, createSpan("a", 1, 0))))
+	st, err := Open(t.TempDir(), WithMaxSegmentBytes(2*record))
+	require.Nil(err)
+	defer st.Close()
+
+	require.Nil(st.Append(
+		createSpan("a", 1, 0),
+		createSpan("b", 2, time.Second),
+		createSpan("a", 3, 2*time.Second),
+		money.Span{Name: "no-trace-context"},
+	))
+	require.Nil(st.Append(createSpan("c", 4, 3*time.Second), createSpan("a", 5, 4*time.Second)))
+	require.Nil(st.Sync())
+
+	assert.Len(st.segments, 3)
+	assert.Equal(5*record, st.Size())
+
+	spans, err := st.Trace("a")
+	require.Nil(err)
+	assert.Equal([]string{"a/1", "a/3", "a/5"}, spanIDs(spans))
+
+	spans, err = st.Trace("z")
+	require.Nil(err)
+	assert.Empty(spans)
+
+	assert.Equal([]string{"a/1", "b/2", "a/3", "c/4", "a/5"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+	assert.Equal([]string{"b/2", "a/3"}, spanIDs(scanAll(t, st, testEpoch.Add(time.Second), testEpoch.Add(3*time.Second))))
+	assert.Equal([]string{"a/5"}, spanIDs(scanAll(t, st, testEpoch.Add(4*time.Second), time.Time{})))
+}
+
+func TestStoreRecovery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	st, err := Open(dir)
+	require.Nil(err)
+	require.Nil(st.Append(createSpan("a", 1, 0), createSpan("a", 2, time.Second)))
+	path := st.segments[len(st.segments)-1].path
+	require.Nil(st.Close())
+
+	//simulate a crash in the middle of writing a record
+	torn := encode(t, createSpan("a", 3, 2*time.Second))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.Nil(err)
+	_, err = f.Write(torn[:len(torn)-5])
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	st, err = Open(dir)
+	require.Nil(err)
+	defer st.Close()
+
+	info, err := os.Stat(path)
+	require.Nil(err)
+	assert.Equal(2*int64(len(torn)), info.Size())
+
+	require.Nil(st.Append(createSpan("a", 4, 3*time.Second)))
+	spans, err := st.Trace("a")
+	require.Nil(err)
+	assert.Equal([]string{"a/1", "a/2", "a/4"}, spanIDs(spans))
+	assert.Equal([]string{"a/1", "a/2", "a/4"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+}
+
+func TestStoreDelimiters(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	failed := createSpan("a", 2, time.Second)
+	failed.Err = errors.New("dial tcp: refused; retrying")
+	failed.Attributes = map[string]string{"query": "a=1,b=2"}
+
+	st, err := Open(dir)
+	require.Nil(err)
+	require.Nil(st.Append(createSpan("a", 1, 0), failed, createSpan("a", 3, 2*time.Second)))
+	require.Nil(st.Close())
+
+	st, err = Open(dir)
+	require.Nil(err)
+	defer st.Close()
+
+	spans, err := st.Trace("a")
+	require.Nil(err)
+	require.Equal([]string{"a/1", "a/2", "a/3"}, spanIDs(spans))
+	assert.Equal(failed.Err.Error(), spans[1].Err.Error())
+	assert.Equal(failed.Attributes, spans[1].Attributes)
+}
+
+func TestStoreUndecodableRecord(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	st, err := Open(dir)
+	require.Nil(err)
+	require.Nil(st.Append(createSpan("a", 1, 0)))
+	path := st.segments[len(st.segments)-1].path
+	require.Nil(st.Close())
+
+	//an intact record which holds no span, followed by a valid one
+	payload := []byte(`{"span-name":"broken"}`)
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.Nil(err)
+	_, err = f.Write(append(record, encode(t, createSpan("a", 2, time.Second))...))
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	info, err := os.Stat(path)
+	require.Nil(err)
+
+	st, err = Open(dir)
+	require.Nil(err)
+	defer st.Close()
+
+	truncated, err := os.Stat(path)
+	require.Nil(err)
+	assert.Equal(info.Size(), truncated.Size())
+
+	spans, err := st.Trace("a")
+	require.Nil(err)
+	assert.Equal([]string{"a/1", "a/2"}, spanIDs(spans))
+	assert.Equal([]string{"a/1", "a/2"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+}
+
+func TestStoreSpanTooLarge(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	large := createSpan("b", 2, time.Second)
+	large.Attributes = map[string]string{"padding": strings.Repeat("x", 2*maxRecordSize)}
+
+	st, err := Open(dir)
+	require.Nil(err)
+	assert.Equal(ErrSpanTooLarge, st.Append(createSpan("a", 1, 0), large, createSpan("c", 3, 2*time.Second)))
+	require.Nil(st.Close())
+
+	st, err = Open(dir)
+	require.Nil(err)
+	defer st.Close()
+
+	assert.Equal([]string{"a/1", "c/3"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+}
+
+func TestStoreCorruptRecord(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	st, err := Open(dir)
+	require.Nil(err)
+	require.Nil(st.Append(createSpan("a", 1, 0), createSpan("a", 2, time.Second), createSpan("a", 3, 2*time.Second)))
+	path := st.segments[len(st.segments)-1].path
+	require.Nil(st.Close())
+
+	//flip a byte in the payload of the second record
+	record := int64(len(encode(t, createSpan("a", 1, 0))))
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.Nil(err)
+	_, err = f.WriteAt([]byte{'!'}, record+recordHeaderSize+1)
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	st, err = Open(dir)
+	require.Nil(err)
+
+	info, err := os.Stat(path)
+	require.Nil(err)
+	assert.Equal(3*record, info.Size())
+
+	spans, err := st.Trace("a")
+	require.Nil(err)
+	assert.Equal([]string{"a/1", "a/3"}, spanIDs(spans))
+	assert.Equal([]string{"a/1", "a/3"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+	require.Nil(st.Close())
+
+	//a corrupt last record is a torn tail
+	f, err = os.OpenFile(path, os.O_RDWR, 0)
+	require.Nil(err)
+	_, err = f.WriteAt([]byte{'!'}, 2*record+recordHeaderSize+1)
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	st, err = Open(dir)
+	require.Nil(err)
+	defer st.Close()
+
+	info, err = os.Stat(path)
+	require.Nil(err)
+	assert.Equal(2*record, info.Size())
+}
+
+func TestStoreReopen(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	dir := t.TempDir()
+
+	for i := int64(1); i <= 3; i++ {
+		st, err := Open(dir)
+		require.Nil(err)
+		require.Nil(st.Append(createSpan("a", i, 0)))
+		require.Nil(st.Close())
+	}
+
+	ids, err := listSegments(dir)
+	require.Nil(err)
+	assert.Len(ids, 1)
+
+	// opening without appending leaves no empty segments behind
+	for i := 0; i < 3; i++ {
+		st, err := Open(dir)
+		require.Nil(err)
+		require.Nil(st.Close())
+	}
+
+	ids, err = listSegments(dir)
+	require.Nil(err)
+	assert.Len(ids, 1)
+}
+
+func TestStoreRetention(t *testing.T) {
+	record := int64(len(encode(t, createSpan("a", 1, 0))))
+
+	t.Run("Age", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		st, err := Open(t.TempDir(), WithMaxSegmentBytes(record), WithMaxAge(time.Hour))
+		require.Nil(err)
+		defer st.Close()
+
+		st.now = func() time.Time { return testEpoch.Add(90 * time.Minute) }
+		require.Nil(st.Append(createSpan("a", 1, 0), createSpan("b", 2, time.Hour), createSpan("c", 3, time.Hour)))
+
+		// the first segment expired by the end of the append
+		spans, err := st.Trace("a")
+		require.Nil(err)
+		assert.Empty(spans)
+		assert.Equal([]string{"b/2", "c/3"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+
+		require.Nil(st.Retain(testEpoch.Add(3 * time.Hour)))
+		assert.Equal([]string{"c/3"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		st, err := Open(t.TempDir(), WithMaxSegmentBytes(record), WithMaxBytes(2*record))
+		require.Nil(err)
+		defer st.Close()
+
+		require.Nil(st.Append(createSpan("a", 1, 0), createSpan("b", 2, 0), createSpan("c", 3, 0), createSpan("d", 4, 0)))
+		assert.Equal(2*record, st.Size())
+		assert.Equal([]string{"c/3", "d/4"}, spanIDs(scanAll(t, st, time.Time{}, time.Time{})))
+	})
+}
+
+func TestStoreClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	st, err := Open(t.TempDir())
+	require.Nil(t, err)
+	assert.Nil(st.Close())
+
+	assert.Equal(ErrClosed, st.Close())
+	assert.Equal(ErrClosed, st.Append(createSpan("a", 1, 0)))
+	assert.Equal(ErrClosed, st.Sync())
+	assert.Equal(ErrClosed, st.Retain(time.Now()))
+	assert.Equal(ErrClosed, st.Scan(time.Time{}, time.Time{}).Err())
+
+	_, err = st.Trace("a")
+	assert.Equal(ErrClosed, err)
+}
@@ -0,0 +1,390 @@
+package money
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// instrumentationName identifies golang-money in exported formats which name their source
+const instrumentationName = "github.com/xmidt-org/golang-money"
+
+// csvColumns are the SpecSchema keys, in the order they are written by EncodeCSV
+var csvColumns = []string{
+	tIDKey, sIDKey, pIDKey,
+	spanNameKey, appNameKey, startTimeKey, spanDurationKey, spanSuccessKey,
+	responseCodeKey, hostKey, errKey, linksKey,
+}
+
+// EncodeJSONLines writes every span as a JSON object keyed by SpecSchema, one per line.
+func EncodeJSONLines(w io.Writer, spans []Span) error {
+	var e = json.NewEncoder(w)
+
+	for _, s := range spans {
+		m, err := s.MapWith(SpecSchema)
+		if err != nil {
+			return err
+		}
+
+		if err = e.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EncodeCSV writes the spans as CSV records preceded by a header of SpecSchema keys.
+func EncodeCSV(w io.Writer, spans []Span) error {
+	var c = csv.NewWriter(w)
+
+	if err := c.Write(csvColumns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(csvColumns))
+	for _, s := range spans {
+		m, err := s.MapWith(SpecSchema)
+		if err != nil {
+			return err
+		}
+
+		for i, k := range csvColumns {
+			record[i] = m[k]
+		}
+
+		if err = c.Write(record); err != nil {
+			return err
+		}
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+// EncodeZipkin writes the spans as a Zipkin v2 JSON array.
+// Trace-ids which are not hexadecimal are hashed; the original is kept in the money.trace-id tag.
+func EncodeZipkin(w io.Writer, spans []Span) error {
+	out := make([]zipkinSpan, 0, len(spans))
+
+	for _, s := range spans {
+		if s.TC == nil {
+			return errMissingTraceContext
+		}
+
+		z := zipkinSpan{
+			TraceID:       hexTraceID(s.TC.TID),
+			ID:            hexSpanID(s.TC.SID),
+			Name:          s.Name,
+			Timestamp:     s.StartTime.UnixNano() / 1e3,
+			Duration:      s.Duration.Nanoseconds() / 1e3,
+			LocalEndpoint: zipkinEndpoint{ServiceName: s.AppName},
+			Tags:          exportTags(s),
+		}
+
+		if len(s.Links) > 0 {
+			z.Tags[linksKey] = encodeLinks(s.Links)
+		}
+
+		if !isRoot(s.TC) {
+			z.ParentID = hexSpanID(s.TC.PID)
+		}
+
+		if !s.Success {
+			z.Tags["error"] = "true"
+			if s.Err != nil {
+				z.Tags["error"] = s.Err.Error()
+			}
+		}
+
+		out = append(out, z)
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID    string          `json:"traceId"`
+	SpanID     string          `json:"spanId"`
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Links             []otlpLink      `json:"links,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// OTLP status codes and span kinds
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+	otlpKindServer  = 2
+)
+
+// EncodeOTLP writes the spans as an OTLP/JSON trace export request with one resource per AppName.
+func EncodeOTLP(w io.Writer, spans []Span) error {
+	var (
+		byApp = make(map[string][]otlpSpan)
+		apps  []string
+	)
+
+	for _, s := range spans {
+		if s.TC == nil {
+			return errMissingTraceContext
+		}
+
+		o := otlpSpan{
+			TraceID:           hexTraceID(s.TC.TID),
+			SpanID:            hexSpanID(s.TC.SID),
+			Name:              s.Name,
+			Kind:              otlpKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.StartTime.Add(s.Duration).UnixNano(), 10),
+			Attributes:        otlpAttributes(exportTags(s)),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+
+		for _, l := range s.Links {
+			o.Links = append(o.Links, otlpLink{
+				TraceID:    hexTraceID(l.TID),
+				SpanID:     hexSpanID(l.SID),
+				Attributes: otlpAttributes(l.Attributes),
+			})
+		}
+
+		if !isRoot(s.TC) {
+			o.ParentSpanID = hexSpanID(s.TC.PID)
+		}
+
+		if !s.Success {
+			o.Status.Code = otlpStatusError
+			if s.Err != nil {
+				o.Status.Message = s.Err.Error()
+			}
+		}
+
+		if _, ok := byApp[s.AppName]; !ok {
+			apps = append(apps, s.AppName)
+		}
+
+		byApp[s.AppName] = append(byApp[s.AppName], o)
+	}
+
+	out := otlpTraces{ResourceSpans: make([]otlpResourceSpans, 0, len(apps))}
+	for _, app := range apps {
+		out.ResourceSpans = append(out.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: app}}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationName},
+				Spans: byApp[app],
+			}},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
+
+type chromeEvent struct {
+	Name string            `json:"name"`
+	Cat  string            `json:"cat,omitempty"`
+	Ph   string            `json:"ph"`
+	Ts   int64             `json:"ts"`
+	Dur  int64             `json:"dur,omitempty"`
+	Pid  int               `json:"pid"`
+	Tid  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents []chromeEvent `json:"traceEvents"`
+}
+
+// EncodeChromeTrace writes the spans in the Chrome Trace Event format, which is
+// understood by chrome://tracing and Perfetto. Every trace-id becomes a process and
+// every AppName within it a thread.
+func EncodeChromeTrace(w io.Writer, spans []Span) error {
+	var (
+		pids   = make(map[string]int)
+		tids   = make(map[string]int)
+		events = make([]chromeEvent, 0, len(spans))
+	)
+
+	for _, s := range spans {
+		if s.TC == nil {
+			return errMissingTraceContext
+		}
+
+		pid, ok := pids[s.TC.TID]
+		if !ok {
+			pid = len(pids) + 1
+			pids[s.TC.TID] = pid
+			events = append(events, chromeEvent{
+				Name: "process_name", Ph: "M", Pid: pid,
+				Args: map[string]string{"name": tIDKey + "=" + s.TC.TID},
+			})
+		}
+
+		threadKey := s.TC.TID + ";" + s.AppName
+		tid, ok := tids[threadKey]
+		if !ok {
+			tid = len(tids) + 1
+			tids[threadKey] = tid
+			events = append(events, chromeEvent{
+				Name: "thread_name", Ph: "M", Pid: pid, Tid: tid,
+				Args: map[string]string{"name": s.AppName},
+			})
+		}
+
+		args := exportTags(s)
+		args[sIDKey] = strconv.FormatInt(s.TC.SID, 10)
+		args[pIDKey] = strconv.FormatInt(s.TC.PID, 10)
+		args[spanSuccessKey] = strconv.FormatBool(s.Success)
+		if len(s.Links) > 0 {
+			args[linksKey] = encodeLinks(s.Links)
+		}
+
+		events = append(events, chromeEvent{
+			Name: s.Name,
+			Cat:  s.AppName,
+			Ph:   "X",
+			Ts:   s.StartTime.UnixNano() / 1e3,
+			Dur:  s.Duration.Nanoseconds() / 1e3,
+			Pid:  pid,
+			Tid:  tid,
+			Args: args,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(chromeTrace{TraceEvents: events})
+}
+
+// exportTags collects the span data which formats without a dedicated field carry as tags
+func exportTags(s Span) map[string]string {
+	tags := make(map[string]string, len(s.Attributes)+4)
+	for _, k := range s.attributeKeys() {
+		tags[k] = s.Attributes[k]
+	}
+
+	tags["money."+tIDKey] = s.TC.TID
+
+	if s.Host != "" {
+		tags[hostKey] = s.Host
+	}
+
+	if s.Code != 0 {
+		tags[responseCodeKey] = strconv.Itoa(s.Code)
+	}
+
+	if s.Err != nil {
+		tags[errKey] = s.Err.Error()
+	}
+
+	return tags
+}
+
+func otlpAttributes(tags map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		attributes = append(attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: tags[k]}})
+	}
+
+	return attributes
+}
+
+// isRoot reports whether the trace context starts its trace
+func isRoot(tc *TraceContext) bool {
+	return tc.PID == 0 || tc.PID == tc.SID
+}
+
+// hexTraceID converts a Money trace-id into the 32 hex digit form used by Zipkin and OTLP.
+// Hexadecimal ids such as UUIDs are kept, anything else is hashed.
+func hexTraceID(tid string) string {
+	id := strings.ToLower(strings.Replace(tid, "-", "", -1))
+
+	if _, err := hex.DecodeString(id); err != nil || id == "" || len(id) > 32 || len(id)%2 != 0 {
+		h := fnv.New128a()
+		h.Write([]byte(tid))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	return strings.Repeat("0", 32-len(id)) + id
+}
+
+// hexSpanID converts a Money span-id into the 16 hex digit form used by Zipkin and OTLP
+func hexSpanID(id int64) string {
+	return fmt.Sprintf("%016x", uint64(id))
+}
@@ -0,0 +1,166 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createExportSpans() []Span {
+	start := time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	return []Span{
+		{
+			Name:      "ServeHTTP",
+			AppName:   "scytale",
+			TC:        &TraceContext{TID: "de305d54-75b4-431b-adb2-eb6b9e546013", PID: 1, SID: 1},
+			Success:   true,
+			Code:      200,
+			StartTime: start,
+			Duration:  100 * time.Millisecond,
+			Host:      "localhost",
+		},
+		{
+			Name:      "fanout",
+			AppName:   "talaria",
+			TC:        &TraceContext{TID: "de305d54-75b4-431b-adb2-eb6b9e546013", PID: 1, SID: 255},
+			Code:      503,
+			Err:       errors.New("unavailable"),
+			StartTime: start.Add(10 * time.Millisecond),
+			Duration:  50 * time.Millisecond,
+			Links:     []Link{{TID: "abc", SID: 7, Attributes: map[string]string{"device": "mac:112233445566"}}},
+		},
+	}
+}
+
+func TestEncodeJSONLines(t *testing.T) {
+	assert := assert.New(t)
+	var b bytes.Buffer
+
+	assert.Nil(EncodeJSONLines(&b, createExportSpans()))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(lines, 2)
+
+	var m SpanMap
+	assert.Nil(json.Unmarshal([]byte(lines[1]), &m))
+
+	s, err := SpanFromMap(m)
+	assert.Nil(err)
+	assert.Equal(createExportSpans()[1].String(), s.String())
+}
+
+func TestEncodeCSV(t *testing.T) {
+	assert := assert.New(t)
+	var b bytes.Buffer
+
+	assert.Nil(EncodeCSV(&b, createExportSpans()))
+	assert.Equal("trace-id,span-id,parent-id,span-name,app-name,start-time,span-duration,span-success,response-code,host,err,links\n"+
+		"de305d54-75b4-431b-adb2-eb6b9e546013,1,1,ServeHTTP,scytale,2019-05-01T10:30:00Z,100000000ns,true,200,localhost,,\n"+
+		"de305d54-75b4-431b-adb2-eb6b9e546013,255,1,fanout,talaria,2019-05-01T10:30:00.01Z,50000000ns,false,503,,unavailable,"+
+		"trace-id=abc&span-id=7&device=mac%3A112233445566\n",
+		b.String())
+}
+
+func TestEncodeZipkin(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		b   bytes.Buffer
+		out []map[string]interface{}
+	)
+
+	assert.Nil(EncodeZipkin(&b, createExportSpans()))
+	assert.Nil(json.Unmarshal(b.Bytes(), &out))
+	assert.Len(out, 2)
+
+	assert.Equal("de305d5475b4431badb2eb6b9e546013", out[0]["traceId"])
+	assert.Equal("0000000000000001", out[0]["id"])
+	assert.Nil(out[0]["parentId"])
+	assert.Equal(float64(1556706600000000), out[0]["timestamp"])
+	assert.Equal(float64(100000), out[0]["duration"])
+	assert.Equal(map[string]interface{}{"serviceName": "scytale"}, out[0]["localEndpoint"])
+
+	assert.Equal("00000000000000ff", out[1]["id"])
+	assert.Equal("0000000000000001", out[1]["parentId"])
+	assert.Equal("unavailable", out[1]["tags"].(map[string]interface{})["error"])
+	assert.Equal("trace-id=abc&span-id=7&device=mac%3A112233445566", out[1]["tags"].(map[string]interface{})["links"])
+}
+
+func TestEncodeOTLP(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		b   bytes.Buffer
+		out otlpTraces
+	)
+
+	assert.Nil(EncodeOTLP(&b, createExportSpans()))
+	assert.Nil(json.Unmarshal(b.Bytes(), &out))
+	assert.Len(out.ResourceSpans, 2)
+
+	talaria := out.ResourceSpans[1]
+	assert.Equal("talaria", talaria.Resource.Attributes[0].Value.StringValue)
+	assert.Equal(instrumentationName, talaria.ScopeSpans[0].Scope.Name)
+
+	span := talaria.ScopeSpans[0].Spans[0]
+	assert.Equal("00000000000000ff", span.SpanID)
+	assert.Equal("0000000000000001", span.ParentSpanID)
+	assert.Equal("1556706600010000000", span.StartTimeUnixNano)
+	assert.Equal("1556706600060000000", span.EndTimeUnixNano)
+	assert.Equal(otlpStatus{Code: otlpStatusError, Message: "unavailable"}, span.Status)
+	assert.Equal([]otlpLink{{
+		TraceID:    hexTraceID("abc"),
+		SpanID:     "0000000000000007",
+		Attributes: []otlpAttribute{{Key: "device", Value: otlpValue{StringValue: "mac:112233445566"}}},
+	}}, span.Links)
+}
+
+func TestEncodeChromeTrace(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		b   bytes.Buffer
+		out chromeTrace
+	)
+
+	assert.Nil(EncodeChromeTrace(&b, createExportSpans()))
+	assert.Nil(json.Unmarshal(b.Bytes(), &out))
+
+	// one process for the trace, one thread per app, one event per span
+	assert.Len(out.TraceEvents, 5)
+	assert.Equal("process_name", out.TraceEvents[0].Name)
+
+	fanout := out.TraceEvents[4]
+	assert.Equal("fanout", fanout.Name)
+	assert.Equal("X", fanout.Ph)
+	assert.Equal(int64(1556706600010000), fanout.Ts)
+	assert.Equal(int64(50000), fanout.Dur)
+	assert.Equal(1, fanout.Pid)
+	assert.Equal(2, fanout.Tid)
+}
+
+func TestEncodeMissingTraceContext(t *testing.T) {
+	var spans = []Span{{Name: "test-span"}}
+
+	for name, encode := range map[string]func(*bytes.Buffer, []Span) error{
+		"JSONLines": func(b *bytes.Buffer, s []Span) error { return EncodeJSONLines(b, s) },
+		"CSV":       func(b *bytes.Buffer, s []Span) error { return EncodeCSV(b, s) },
+		"Zipkin":    func(b *bytes.Buffer, s []Span) error { return EncodeZipkin(b, s) },
+		"OTLP":      func(b *bytes.Buffer, s []Span) error { return EncodeOTLP(b, s) },
+		"Chrome":    func(b *bytes.Buffer, s []Span) error { return EncodeChromeTrace(b, s) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, errMissingTraceContext, encode(new(bytes.Buffer), spans))
+		})
+	}
+}
+
+func TestHexTraceID(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("000000000000000000000000000000ab", hexTraceID("AB"))
+	assert.Len(hexTraceID("test-trace"), 32)
+	assert.NotEqual(hexTraceID("test-trace"), hexTraceID("test-trace2"))
+}
@@ -0,0 +1,258 @@
+package money
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// LogFormat selects how a LogExporter writes spans
+type LogFormat int
+
+const (
+	// StringFormat writes Span.String, one span per line
+	StringFormat LogFormat = iota
+
+	// LogfmtFormat writes the SpecSchema keys and values of a span as logfmt, one span per line
+	LogfmtFormat
+
+	// JSONFormat writes the SpecSchema map of a span as JSON, one span per line
+	JSONFormat
+)
+
+// Defaults of a LogExporter
+const (
+	DefaultLogQueueSize     = 1024
+	DefaultLogFlushInterval = time.Second
+)
+
+// LogExporterOptions configure a LogExporter
+type LogExporterOptions func(*LogExporter)
+
+// WithLogFormat sets the format spans are written in, StringFormat by default
+func WithLogFormat(f LogFormat) LogExporterOptions {
+	return func(e *LogExporter) {
+		e.format = f
+	}
+}
+
+// WithLogQueueSize sets how many finished spans may wait to be written before
+// further ones are dropped. Sizes below 1 are ignored.
+func WithLogQueueSize(n int) LogExporterOptions {
+	return func(e *LogExporter) {
+		if n > 0 {
+			e.queue = make(chan Span, n)
+		}
+	}
+}
+
+// WithLogFlushInterval sets how often buffered spans are flushed to the writer.
+// Non-positive intervals are ignored.
+func WithLogFlushInterval(d time.Duration) LogExporterOptions {
+	return func(e *LogExporter) {
+		if d > 0 {
+			e.flushInterval = d
+		}
+	}
+}
+
+// LogExporter is a SpanProcessor which writes every finished span to an io.Writer.
+// Spans are queued and written, buffered, by a single goroutine, so trackers of any
+// number of goroutines can share an exporter and a slow writer never blocks them:
+// when the queue is full, spans are dropped and counted instead.
+type LogExporter struct {
+	format        LogFormat
+	flushInterval time.Duration
+
+	w       *bufio.Writer
+	queue   chan Span
+	flushes chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	dropped uint64
+	err     error //first write error, owned by the exporting goroutine until stopped
+}
+
+// NewLogExporter returns a running exporter writing to w. Close must be called to
+// write out the spans still queued.
+func NewLogExporter(w io.Writer, options ...LogExporterOptions) *LogExporter {
+	e := &LogExporter{
+		flushInterval: DefaultLogFlushInterval,
+		w:             bufio.NewWriter(w),
+		queue:         make(chan Span, DefaultLogQueueSize),
+		flushes:       make(chan chan error),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	for _, o := range options {
+		o(e)
+	}
+
+	go e.run()
+	return e
+}
+
+// OnFinish queues the span to be written, dropping it if the queue is full or the
+// exporter is closed
+func (e *LogExporter) OnFinish(s Span) {
+	select {
+	case <-e.done:
+		atomic.AddUint64(&e.dropped, 1)
+		return
+	default:
+	}
+
+	select {
+	case e.queue <- s:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// Dropped returns the number of spans which were not written because the queue was full
+// or the exporter closed
+func (e *LogExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Flush writes out all spans queued so far, returning the first write error of the exporter
+func (e *LogExporter) Flush() error {
+	result := make(chan error, 1)
+
+	select {
+	case e.flushes <- result:
+		return <-result
+	case <-e.stopped:
+		return e.err
+	}
+}
+
+// Close writes out all queued spans and stops the exporter, returning its first write error
+func (e *LogExporter) Close() error {
+	e.once.Do(func() {
+		close(e.done)
+	})
+
+	<-e.stopped
+	return e.err
+}
+
+func (e *LogExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-e.queue:
+			e.write(s)
+		case <-ticker.C:
+			e.flush()
+		case result := <-e.flushes:
+			e.drain()
+			result <- e.flush()
+		case <-e.done:
+			e.drain()
+			e.flush()
+			return
+		}
+	}
+}
+
+// drain writes all spans currently queued
+func (e *LogExporter) drain() {
+	for {
+		select {
+		case s := <-e.queue:
+			e.write(s)
+		default:
+			return
+		}
+	}
+}
+
+func (e *LogExporter) flush() error {
+	if err := e.w.Flush(); err != nil && e.err == nil {
+		e.err = err
+	}
+
+	return e.err
+}
+
+func (e *LogExporter) write(s Span) {
+	var line []byte
+
+	switch e.format {
+	case LogfmtFormat:
+		pairs, err := s.specPairs()
+		if err != nil {
+			return
+		}
+
+		line = []byte(logfmt(pairs))
+	case JSONFormat:
+		m, err := s.MapWith(SpecSchema)
+		if err != nil {
+			return
+		}
+
+		line, _ = json.Marshal(m)
+	default:
+		if s.TC == nil {
+			return
+		}
+
+		line = []byte(s.String())
+	}
+
+	line = append(line, '\n')
+	if _, err := e.w.Write(line); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// logfmt encodes the pairs as space separated key=value, quoting values when needed
+func logfmt(pairs [][2]string) string {
+	var b strings.Builder
+
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(p[0])
+		b.WriteByte('=')
+
+		if needsQuoting(p[1]) {
+			b.WriteString(strconv.Quote(p[1]))
+		} else {
+			b.WriteString(p[1])
+		}
+	}
+
+	return b.String()
+}
+
+func needsQuoting(v string) bool {
+	if v == "" {
+		return true
+	}
+
+	for _, r := range v {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package money
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while the exporter writes
+type lockedBuffer struct {
+	m sync.Mutex
+	b bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.String()
+}
+
+// blockingWriter blocks every write until released
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (b blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestLogExporterFormats(t *testing.T) {
+	s := createAssemblySpan("ServeHTTP", "abc", 1, 2, 0, time.Millisecond)
+	s.Code = 200
+	s.Attributes = map[string]string{"agent": "curl 7.1"}
+
+	tests := []struct {
+		name     string
+		format   LogFormat
+		expected string
+	}{
+		{"String", StringFormat, s.String()},
+		{"Logfmt", LogfmtFormat, `span-name=ServeHTTP app-name=test-app span-duration=1000000ns span-success=true parent-id=1 span-id=2 trace-id=abc start-time=2019-05-01T10:30:00Z response-code=200 agent="curl 7.1"`},
+		{"JSON", JSONFormat, `{"agent":"curl 7.1","app-name":"test-app","parent-id":"1","response-code":"200","span-duration":"1000000ns","span-id":"2","span-name":"ServeHTTP","span-success":"true","start-time":"2019-05-01T10:30:00Z","trace-id":"abc"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var b lockedBuffer
+
+			e := NewLogExporter(&b, WithLogFormat(test.format))
+			e.OnFinish(s)
+			e.OnFinish(Span{Name: "no-trace-context"})
+			assert.Nil(e.Flush())
+			assert.Equal(test.expected+"\n", b.String())
+			assert.Nil(e.Close())
+		})
+	}
+}
+
+func TestLogExporterConcurrency(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		b      lockedBuffer
+		wg     sync.WaitGroup
+		e      = NewLogExporter(&b, WithLogFlushInterval(time.Millisecond))
+	)
+
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				e.OnFinish(*createMockSpan())
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Nil(e.Close())
+	assert.Equal(500, strings.Count(b.String(), "\n"))
+	assert.Equal(uint64(0), e.Dropped())
+
+	e.OnFinish(*createMockSpan())
+	assert.Equal(uint64(1), e.Dropped())
+	assert.Nil(e.Flush())
+}
+
+func TestLogExporterSlowWriter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		w      = blockingWriter{release: make(chan struct{})}
+		e      = NewLogExporter(w, WithLogQueueSize(2), WithLogFlushInterval(time.Hour))
+	)
+
+	// each span is larger than the buffer of the exporter, so the first write blocks
+	s := *createMockSpan()
+	s.Attributes = map[string]string{"padding": strings.Repeat("x", 8192)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			e.OnFinish(s)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnFinish blocked on a slow writer")
+	}
+
+	assert.True(e.Dropped() >= 7, e.Dropped())
+	close(w.release)
+	assert.Nil(e.Close())
+}
+
+func TestLogExporterWriteError(t *testing.T) {
+	e := NewLogExporter(failingWriter{})
+	e.OnFinish(*createMockSpan())
+	assert.EqualError(t, e.Close(), "disk full")
+	assert.EqualError(t, e.Close(), "disk full")
+}
+
+func TestLogExporterOptions(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		assert := assert.New(t)
+		e := NewLogExporter(&lockedBuffer{}, WithLogQueueSize(n), WithLogFlushInterval(time.Duration(n)))
+
+		assert.Equal(DefaultLogQueueSize, cap(e.queue))
+		assert.Equal(DefaultLogFlushInterval, e.flushInterval)
+		assert.Nil(e.Close())
+	}
+}
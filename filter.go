@@ -0,0 +1,189 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// spanCountKey is the attribute of a summary span holding the number of spans it replaced
+const spanCountKey = "span-count"
+
+// undecodableSpansKey is the attribute of a tracker's span holding the number of
+// X-MoneySpans values its span filters dropped because they could not be decoded
+const undecodableSpansKey = "undecodable-spans"
+
+// ErrUndecodableSpans is wrapped by the error of FilterSpans when values were dropped
+var ErrUndecodableSpans = errors.New("undecodable spans")
+
+// SpanFilter redacts or drops spans before they leave the process, i.e. through
+// Tracker.Spans into an X-MoneySpans response header
+type SpanFilter func([]Span) []Span
+
+// DropSpans returns a filter dropping every span for which drop returns true
+func DropSpans(drop func(Span) bool) SpanFilter {
+	return func(spans []Span) []Span {
+		kept := spans[:0:0]
+		for _, s := range spans {
+			if !drop(s) {
+				kept = append(kept, s)
+			}
+		}
+
+		return kept
+	}
+}
+
+// DropApps returns a filter dropping the spans of the given applications
+func DropApps(apps ...string) SpanFilter {
+	set := toSet(apps)
+	return DropSpans(func(s Span) bool { return set[s.AppName] })
+}
+
+// DropNames returns a filter dropping the spans with any of the given names
+func DropNames(names ...string) SpanFilter {
+	set := toSet(names)
+	return DropSpans(func(s Span) bool { return set[s.Name] })
+}
+
+// StripHost returns a filter removing the host from every span
+func StripHost() SpanFilter {
+	return func(spans []Span) []Span {
+		for i := range spans {
+			spans[i].Host = ""
+		}
+
+		return spans
+	}
+}
+
+// StripErr returns a filter removing the error from every span. Success is kept.
+func StripErr() SpanFilter {
+	return func(spans []Span) []Span {
+		for i := range spans {
+			spans[i].Err = nil
+		}
+
+		return spans
+	}
+}
+
+// Summarize returns a filter collapsing the spans of every trace into a single span with
+// the given name. The summary takes the trace context, app name, host and code of the top
+// span, that is the root or else the earliest span without a parent among the spans.
+// It covers the time from the earliest start to the latest end of all spans, succeeds if
+// all spans succeeded and records their number as the span-count attribute.
+func Summarize(name string) SpanFilter {
+	return func(spans []Span) []Span {
+		var summaries []Span
+
+		for _, t := range AssembleTraces(spans) {
+			var (
+				top   = t.Roots
+				count = len(t.Duplicates)
+			)
+
+			if len(top) == 0 {
+				top = t.Orphans
+			}
+
+			if len(top) == 0 {
+				continue
+			}
+
+			s := Span{
+				Name:       name,
+				AppName:    top[0].Span.AppName,
+				TC:         top[0].Span.TC,
+				Code:       top[0].Span.Code,
+				Host:       top[0].Span.Host,
+				Success:    true,
+				StartTime:  top[0].Span.StartTime,
+				Attributes: map[string]string{},
+			}
+
+			end := top[0].End()
+
+			var visit func(nodes []*TraceNode)
+			visit = func(nodes []*TraceNode) {
+				for _, n := range nodes {
+					count++
+					s.Success = s.Success && n.Span.Success
+
+					if n.Span.StartTime.Before(s.StartTime) {
+						s.StartTime = n.Span.StartTime
+					}
+
+					if n.End().After(end) {
+						end = n.End()
+					}
+
+					visit(n.Children)
+				}
+			}
+
+			visit(t.Roots)
+			visit(t.Orphans)
+
+			for _, d := range t.Duplicates {
+				s.Success = s.Success && d.Span.Success
+			}
+
+			s.Duration = end.Sub(s.StartTime)
+			s.Attributes[spanCountKey] = strconv.Itoa(count)
+			summaries = append(summaries, s)
+		}
+
+		return summaries
+	}
+}
+
+// FilterSpans decodes the string-encoded spans, applies the filters in order and
+// encodes the remaining spans. A value may hold several spans joined as ParseSpans
+// accepts them. Names, hosts and errors holding an unescaped ';' are tolerated as long
+// as no '=' follows it, but a span with anything after its error is not: an unescaped
+// error could have made it up. Values which cannot be decoded are dropped, since they
+// cannot be redacted, and reported by an error wrapping ErrUndecodableSpans.
+func FilterSpans(encoded []string, filters ...SpanFilter) ([]string, error) {
+	spans, undecodable := decodeSpans(encoded)
+
+	for _, f := range filters {
+		spans = f(spans)
+	}
+
+	filtered := make([]string, 0, len(spans))
+	for _, s := range spans {
+		filtered = append(filtered, s.String())
+	}
+
+	if undecodable > 0 {
+		return filtered, fmt.Errorf("%w: dropped %d of %d values", ErrUndecodableSpans, undecodable, len(encoded))
+	}
+
+	return filtered, nil
+}
+
+// decodeSpans decodes the spans of all values, counting the values which do not decode
+func decodeSpans(encoded []string) (spans []Span, undecodable int) {
+	for _, raw := range encoded {
+		decoded, err := parseSpans(raw, true)
+		if err != nil || (len(decoded) == 0 && strings.TrimSpace(raw) != "") {
+			undecodable++
+			continue
+		}
+
+		spans = append(spans, decoded...)
+	}
+
+	return
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+
+	return set
+}
@@ -0,0 +1,172 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createFilterSpans() []Span {
+	root := createAssemblySpan("ServeHTTP", "a", 1, 1, 0, 100*time.Millisecond)
+	root.AppName, root.Host, root.Code = "scytale", "scytale-1.internal", 200
+
+	fanout := createAssemblySpan("fanout", "a", 1, 2, 10*time.Millisecond, 120*time.Millisecond)
+	fanout.AppName, fanout.Host, fanout.Success, fanout.Err = "talaria", "talaria-3.internal", false, errors.New("dial tcp 10.0.0.3: refused")
+
+	query := createAssemblySpan("query", "a", 2, 3, 20*time.Millisecond, 10*time.Millisecond)
+	query.AppName = "petasos"
+
+	return []Span{root, fanout, query}
+}
+
+func TestSpanFilters(t *testing.T) {
+	t.Run("DropApps", func(t *testing.T) {
+		assert.Equal(t, []string{"ServeHTTP", "query"}, spanNames(DropApps("talaria")(createFilterSpans())))
+	})
+
+	t.Run("DropNames", func(t *testing.T) {
+		assert.Equal(t, []string{"ServeHTTP"}, spanNames(DropNames("fanout", "query")(createFilterSpans())))
+	})
+
+	t.Run("Strip", func(t *testing.T) {
+		assert := assert.New(t)
+		spans := StripErr()(StripHost()(createFilterSpans()))
+
+		for _, s := range spans {
+			assert.Empty(s.Host)
+			assert.Nil(s.Err)
+		}
+
+		assert.False(spans[1].Success)
+	})
+
+	t.Run("Summarize", func(t *testing.T) {
+		assert := assert.New(t)
+		spans := createFilterSpans()
+		other := createAssemblySpan("other", "b", 5, 6, 0, time.Millisecond)
+
+		summaries := Summarize("scytale-request")(append(spans, other))
+		assert.Len(summaries, 2)
+
+		s := summaries[0]
+		assert.Equal("scytale-request", s.Name)
+		assert.Equal("scytale", s.AppName)
+		assert.Equal(TraceContext{TID: "a", PID: 1, SID: 1}, *s.TC)
+		assert.Equal(200, s.Code)
+		assert.False(s.Success)
+		assert.Nil(s.Err)
+		assert.Equal(assemblyEpoch, s.StartTime)
+		assert.Equal(130*time.Millisecond, s.Duration)
+		assert.Equal(map[string]string{spanCountKey: "3"}, s.Attributes)
+
+		// a trace without root is summarized by its orphan
+		assert.Equal("b", summaries[1].TC.TID)
+		assert.True(summaries[1].Success)
+	})
+}
+
+func TestFilterSpans(t *testing.T) {
+	t.Run("Values", func(t *testing.T) {
+		assert := assert.New(t)
+		spans := createFilterSpans()
+
+		// the first two spans are joined into a single value
+		encoded := []string{spans[0].String() + "," + spans[1].String(), spans[2].String()}
+
+		filtered, err := FilterSpans(encoded, DropApps("petasos"), StripHost())
+		assert.Nil(err)
+		assert.Len(filtered, 2)
+
+		for _, raw := range filtered {
+			s, err := ParseSpan(raw)
+			assert.Nil(err)
+			assert.Empty(s.Host)
+		}
+	})
+
+	t.Run("UnescapedErr", func(t *testing.T) {
+		assert := assert.New(t)
+		s := createFilterSpans()[1]
+		s.Err = errors.New("dial tcp: refused; retrying")
+
+		filtered, err := FilterSpans([]string{s.String()}, StripErr())
+		assert.Nil(err)
+		assert.Len(filtered, 1)
+		assert.NotContains(filtered[0], "refused")
+	})
+
+	t.Run("ErrWithPairs", func(t *testing.T) {
+		assert := assert.New(t)
+		s := createFilterSpans()[1]
+		s.Err = errors.New(`Get "http://x/a;b=c": dial tcp: refused`)
+
+		filtered, err := FilterSpans([]string{s.String()}, StripErr())
+		assert.Nil(err)
+		assert.Len(filtered, 1)
+		assert.NotContains(filtered[0], "dial tcp")
+		assert.NotContains(filtered[0], "b=c")
+
+		// as sent by peers which do not escape errors, the pairs might be made up
+		s.Err = nil
+		unescaped := s.String() + `;err=Get "http://x/a;b=c": dial tcp: refused`
+
+		filtered, err = FilterSpans([]string{unescaped}, StripErr())
+		assert.ErrorIs(err, ErrUndecodableSpans)
+		assert.Empty(filtered)
+	})
+
+	t.Run("Undecodable", func(t *testing.T) {
+		assert := assert.New(t)
+		encoded := []string{createFilterSpans()[0].String(), "not-a-span", "span-name=x;trace-id"}
+
+		filtered, err := FilterSpans(encoded)
+		assert.ErrorIs(err, ErrUndecodableSpans)
+		assert.EqualError(err, "undecodable spans: dropped 2 of 3 values")
+		assert.Equal(encoded[:1], filtered)
+	})
+}
+
+func TestHTTPTrackerSpanFilters(t *testing.T) {
+	var (
+		assert    = assert.New(t)
+		processed []Span
+	)
+
+	downstream := createFilterSpans()[2]
+	transactor := func(r *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		rec.Header()[MoneySpansHeader] = []string{downstream.String(), "not-a-span"}
+		return rec.Result(), nil
+	}
+
+	spanner := NewHTTPSpanner(
+		WithSpanFilters(DropApps("petasos"), StripErr()),
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { processed = append(processed, s) })),
+	)
+
+	tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "a", PID: 1, SID: 1}})
+	_, err := tracker.DecorateTransactor(transactor)(httptest.NewRequest("GET", "/", nil))
+	assert.Nil(err)
+
+	tracker.Finish(Result{Name: "ServeHTTP", AppName: "scytale", Err: errors.New("internal detail")})
+
+	assert.Len(tracker.(*HTTPTracker).spans, 3)
+	assert.Equal("1", processed[0].Attributes[undecodableSpansKey])
+
+	spans := tracker.Spans()
+	assert.Len(spans, 1)
+	assert.NotContains(spans[0], "internal detail")
+	assert.EqualError(processed[0].Err, "internal detail")
+}
+
+func spanNames(spans []Span) (n []string) {
+	for _, s := range spans {
+		n = append(n, s.Name)
+	}
+	return
+}
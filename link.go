@@ -0,0 +1,93 @@
+package money
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// linksKey is the Money spec style key under which the links of a span are encoded
+const linksKey = "links"
+
+var errBadLink = errors.New("expected link to have a trace-id and a span-id")
+
+// Link references a span of another trace, or another span of the same trace, which
+// contributed to a span without being its parent. A batch span, for example, links the
+// spans which produced each of its messages.
+type Link struct {
+	TID string
+	SID int64
+
+	// Attributes describe the relationship, i.e. the message the link stands for
+	Attributes map[string]string
+}
+
+// encodeLinks encodes every link as query parameters and joins them by commas:
+// trace-id=abc&span-id=1&key=value,trace-id=def&span-id=2
+// Escaping keeps the result free of the delimiters of Span.String.
+func encodeLinks(links []Link) string {
+	var encoded = make([]string, 0, len(links))
+
+	for _, l := range links {
+		var b strings.Builder
+		b.WriteString(tIDKey + "=" + url.QueryEscape(l.TID))
+		b.WriteString("&" + sIDKey + "=" + strconv.FormatInt(l.SID, 10))
+
+		keys := make([]string, 0, len(l.Attributes))
+		for k := range l.Attributes {
+			if k != tIDKey && k != sIDKey {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString("&" + url.QueryEscape(k) + "=" + url.QueryEscape(l.Attributes[k]))
+		}
+
+		encoded = append(encoded, b.String())
+	}
+
+	return strings.Join(encoded, ",")
+}
+
+// decodeLinks is the inverse of encodeLinks
+func decodeLinks(v string) ([]Link, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	var links []Link
+	for _, raw := range strings.Split(v, ",") {
+		q, err := url.ParseQuery(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		l := Link{TID: q.Get(tIDKey)}
+		if l.TID == "" {
+			return nil, errBadLink
+		}
+
+		if l.SID, err = strconv.ParseInt(q.Get(sIDKey), 10, 64); err != nil {
+			return nil, errBadLink
+		}
+
+		delete(q, tIDKey)
+		delete(q, sIDKey)
+
+		for k := range q {
+			if l.Attributes == nil {
+				l.Attributes = make(map[string]string, len(q))
+			}
+
+			l.Attributes[k] = q.Get(k)
+		}
+
+		links = append(links, l)
+	}
+
+	return links, nil
+}
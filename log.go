@@ -0,0 +1,168 @@
+package money
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// maxScopedHandlers bounds the handlers a grouped logHandler caches per trace context
+const maxScopedHandlers = 256
+
+// NewLogHandler wraps h such that records logged with a context holding a Tracker
+// (see TrackerFromContext) carry the trace-id, span-id and parent-id of its span.
+// The tracker must implement TraceContextTracker, as HTTPTracker does. The trace
+// context stays at the top level of the record, outside of any groups of the logger.
+// Within groups this takes a handler of its own per trace context, which is built by
+// replaying the groups and attributes of the logger and cached for the spans most
+// recently logged with, so a grouped logger is best derived once rather than per call.
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{next: h, root: h}
+}
+
+type logHandler struct {
+	next slog.Handler
+
+	//root is the handler before the first group and scoped replays the groups,
+	//and the attributes added within them, onto another handler
+	root   slog.Handler
+	scoped []func(slog.Handler) slog.Handler
+
+	//cache holds the replayed handlers by trace context, if scoped
+	cache *scopedHandlers
+}
+
+// scopedHandlers caches the handlers of a grouped logHandler by trace context.
+// Once full, it is emptied rather than evicting entries one by one.
+type scopedHandlers struct {
+	m        sync.Mutex
+	handlers map[TraceContext]slog.Handler
+}
+
+func (c *scopedHandlers) get(tc TraceContext, build func() slog.Handler) slog.Handler {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if h, ok := c.handlers[tc]; ok {
+		return h
+	}
+
+	if len(c.handlers) >= maxScopedHandlers {
+		clear(c.handlers)
+	}
+
+	h := build()
+	c.handlers[tc] = h
+	return h
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	t, ok := TrackerFromContext(ctx)
+	if !ok {
+		return h.next.Handle(ctx, r)
+	}
+
+	tct, ok := t.(TraceContextTracker)
+	if !ok {
+		return h.next.Handle(ctx, r)
+	}
+
+	tc := tct.TraceContext()
+	if tc == nil {
+		return h.next.Handle(ctx, r)
+	}
+
+	if len(h.scoped) == 0 {
+		r = r.Clone()
+		r.AddAttrs(traceContextAttrs(tc)...)
+		return h.next.Handle(ctx, r)
+	}
+
+	next := h.cache.get(*tc, func() slog.Handler {
+		next := h.root.WithAttrs(traceContextAttrs(tc))
+		for _, scope := range h.scoped {
+			next = scope(next)
+		}
+
+		return next
+	})
+
+	return next.Handle(ctx, r)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.scoped) == 0 {
+		next := h.next.WithAttrs(attrs)
+		return &logHandler{next: next, root: next}
+	}
+
+	return h.with(h.next.WithAttrs(attrs), func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return h.with(h.next.WithGroup(name), func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+// with returns a handler scoped by one more group or set of attributes within a group
+func (h *logHandler) with(next slog.Handler, scope func(slog.Handler) slog.Handler) *logHandler {
+	scoped := make([]func(slog.Handler) slog.Handler, len(h.scoped), len(h.scoped)+1)
+	copy(scoped, h.scoped)
+
+	return &logHandler{
+		next:   next,
+		root:   h.root,
+		scoped: append(scoped, scope),
+		cache:  &scopedHandlers{handlers: make(map[TraceContext]slog.Handler)},
+	}
+}
+
+func traceContextAttrs(tc *TraceContext) []slog.Attr {
+	return []slog.Attr{
+		slog.String(tIDKey, tc.TID),
+		slog.Int64(sIDKey, tc.SID),
+		slog.Int64(pIDKey, tc.PID),
+	}
+}
+
+// SpanAttrs returns the span as structured log attributes keyed as in Span.String
+func SpanAttrs(s Span) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String(spanNameKey, s.Name),
+		slog.String(appNameKey, s.AppName),
+		slog.Duration(spanDurationKey, s.Duration),
+		slog.Bool(spanSuccessKey, s.Success),
+	}
+
+	if s.TC != nil {
+		attrs = append(attrs, traceContextAttrs(s.TC)...)
+	}
+
+	attrs = append(attrs, slog.Time(startTimeKey, s.StartTime))
+
+	if s.Host != "" {
+		attrs = append(attrs, slog.String(hostKey, s.Host))
+	}
+
+	if s.Code != 0 {
+		attrs = append(attrs, slog.Int(responseCodeKey, s.Code))
+	}
+
+	if s.Err != nil {
+		attrs = append(attrs, slog.String(errKey, s.Err.Error()))
+	}
+
+	for _, k := range s.attributeKeys() {
+		attrs = append(attrs, slog.String(k, s.Attributes[k]))
+	}
+
+	return attrs
+}
+
+// LogSpan logs a finished span as structured attributes at the given level
+func LogSpan(ctx context.Context, logger *slog.Logger, level slog.Level, s Span) {
+	logger.LogAttrs(ctx, level, "span finished", SpanAttrs(s)...)
+}
@@ -0,0 +1,151 @@
+package money
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeLogLine(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	return m
+}
+
+// plainTracker hides the TraceContext method of the tracker it wraps
+type plainTracker struct {
+	Tracker
+}
+
+func TestLogHandler(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		b      bytes.Buffer
+		logger = slog.New(NewLogHandler(slog.NewJSONHandler(&b, nil))).With("service", "test")
+	)
+
+	tracker := NewHTTPSpanner().Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 2}})
+	ctx := context.WithValue(context.Background(), contextKeyTracker, tracker)
+
+	logger.InfoContext(ctx, "traced")
+	m := decodeLogLine(t, &b)
+	assert.Equal("abc", m["trace-id"])
+	assert.Equal(float64(2), m["span-id"])
+	assert.Equal(float64(1), m["parent-id"])
+	assert.Equal("test", m["service"])
+
+	logger.WithGroup("request").With("method", "GET").WithGroup("device").InfoContext(ctx, "grouped", "id", "mac:112233445566")
+	m = decodeLogLine(t, &b)
+	assert.Equal("abc", m["trace-id"])
+	assert.Equal(float64(2), m["span-id"])
+	assert.Equal("test", m["service"])
+
+	request := m["request"].(map[string]interface{})
+	assert.Equal("GET", request["method"])
+	assert.NotContains(request, "trace-id")
+	assert.Equal(map[string]interface{}{"id": "mac:112233445566"}, request["device"])
+
+	// trackers without a trace context are not an error
+	logger.InfoContext(context.WithValue(context.Background(), contextKeyTracker, plainTracker{tracker}), "plain")
+	m = decodeLogLine(t, &b)
+	assert.NotContains(m, "trace-id")
+
+	logger.Info("untraced")
+	m = decodeLogLine(t, &b)
+	assert.NotContains(m, "trace-id")
+
+	assert.False(logger.Handler().Enabled(ctx, slog.LevelDebug))
+}
+
+func TestLogHandlerCache(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		b      bytes.Buffer
+		logger = slog.New(NewLogHandler(slog.NewJSONHandler(&b, nil))).WithGroup("request")
+		cache  = logger.Handler().(*logHandler).cache
+	)
+
+	for sid := int64(1); sid <= maxScopedHandlers+1; sid++ {
+		tracker := NewHTTPSpanner().Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: sid}})
+		ctx := context.WithValue(context.Background(), contextKeyTracker, tracker)
+
+		for i := 0; i < 2; i++ {
+			logger.InfoContext(ctx, "traced")
+			assert.Equal(float64(sid), decodeLogLine(t, &b)["span-id"])
+		}
+
+		if sid == maxScopedHandlers {
+			assert.Len(cache.handlers, maxScopedHandlers)
+		}
+	}
+
+	// a full cache is emptied
+	assert.Len(cache.handlers, 1)
+}
+
+func BenchmarkLogHandler(b *testing.B) {
+	tracker := NewHTTPSpanner().Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 2}})
+	ctx := context.WithValue(context.Background(), contextKeyTracker, tracker)
+
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(io.Discard, nil))).With("service", "test")
+
+	b.Run("Plain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			logger.InfoContext(ctx, "traced", "id", i)
+		}
+	})
+
+	b.Run("Grouped", func(b *testing.B) {
+		grouped := logger.WithGroup("request").With("method", "GET")
+		for i := 0; i < b.N; i++ {
+			grouped.InfoContext(ctx, "traced", "id", i)
+		}
+	})
+}
+
+func TestLogSpan(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		b      bytes.Buffer
+		logger = slog.New(slog.NewJSONHandler(&b, nil))
+	)
+
+	s := Span{
+		Name:       "ServeHTTP",
+		AppName:    "test-app",
+		TC:         &TraceContext{TID: "abc", PID: 1, SID: 2},
+		Code:       503,
+		Err:        errors.New("unavailable"),
+		StartTime:  time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC),
+		Duration:   time.Second,
+		Host:       "localhost",
+		Attributes: map[string]string{"route": "/devices"},
+	}
+
+	LogSpan(context.Background(), logger, slog.LevelWarn, s)
+	m := decodeLogLine(t, &b)
+
+	assert.Equal("WARN", m["level"])
+	assert.Equal("span finished", m["msg"])
+	assert.Equal("ServeHTTP", m["span-name"])
+	assert.Equal("test-app", m["app-name"])
+	assert.Equal(float64(time.Second), m["span-duration"])
+	assert.Equal(false, m["span-success"])
+	assert.Equal("abc", m["trace-id"])
+	assert.Equal("2019-05-01T10:30:00Z", m["start-time"])
+	assert.Equal("localhost", m["host"])
+	assert.Equal(float64(503), m["response-code"])
+	assert.Equal("unavailable", m["err"])
+	assert.Equal("/devices", m["route"])
+}
@@ -0,0 +1,349 @@
+// Package metrics derives RED (rate, errors, duration) metrics from finished
+// golang-money spans and serves them in the Prometheus text exposition format.
+// Scrapers accepting OpenMetrics also receive, for every histogram bucket, an
+// exemplar linking to the trace of the most recent span observed in the bucket.
+//
+//	p := metrics.NewProcessor()
+//	spanner := money.NewHTTPSpanner(money.WithSpanProcessors(p))
+//	http.Handle("/metrics", p)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the duration histogram buckets
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Defaults of a Processor
+const (
+	DefaultNamespace      = "money"
+	DefaultMaxLabelValues = 100
+)
+
+// OverflowValue replaces label values once a label has reached its cardinality limit
+const OverflowValue = "other"
+
+// Label names, in the order they are written
+const (
+	AppLabel     = "app"
+	SpanLabel    = "span"
+	CodeLabel    = "code"
+	SuccessLabel = "success"
+)
+
+// Content types of the exposition formats
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Exemplar label names
+const (
+	TraceIDLabel = "trace_id"
+	SpanIDLabel  = "span_id"
+)
+
+// maxExemplarRunes is the OpenMetrics limit on the length of an exemplar's label set
+const maxExemplarRunes = 128
+
+// Options configure a Processor
+type Options func(*Processor)
+
+// WithBuckets sets the upper bounds, in seconds, of the duration histogram buckets
+func WithBuckets(buckets ...float64) Options {
+	return func(p *Processor) {
+		p.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(p.buckets)
+	}
+}
+
+// WithNamespace sets the prefix of all metric names
+func WithNamespace(namespace string) Options {
+	return func(p *Processor) {
+		p.namespace = namespace
+	}
+}
+
+// WithMaxLabelValues caps the number of distinct values of every label. Values seen
+// after the cap is reached are replaced by OverflowValue.
+func WithMaxLabelValues(n int) Options {
+	return func(p *Processor) {
+		p.maxLabelValues = n
+	}
+}
+
+// labels identify a single series
+type labels struct {
+	app, span, code, success string
+}
+
+func (l labels) String() string {
+	return fmt.Sprintf(`%s="%s",%s="%s",%s="%s",%s="%s"`,
+		AppLabel, escape(l.app), SpanLabel, escape(l.span),
+		CodeLabel, escape(l.code), SuccessLabel, escape(l.success))
+}
+
+// exemplar is the most recent observation of a histogram bucket
+type exemplar struct {
+	tid, sid string
+	value    float64
+	at       time.Time
+}
+
+func (e *exemplar) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, ` # {%s="%s",%s="%s"} %s`, TraceIDLabel, escape(e.tid), SpanIDLabel, e.sid, formatFloat(e.value))
+	if !e.at.IsZero() {
+		fmt.Fprintf(&b, " %s", strconv.FormatFloat(float64(e.at.UnixNano())/1e9, 'f', 3, 64))
+	}
+
+	return b.String()
+}
+
+// series holds the counter and histogram of a single set of labels
+type series struct {
+	count     uint64
+	sum       float64
+	buckets   []uint64    //non-cumulative counts per bucket
+	exemplars []*exemplar //per bucket, the last one being +Inf
+}
+
+// Processor is a money.SpanProcessor which updates a span counter and a duration
+// histogram per app, span name, code and success of every finished span. It is also
+// the http.Handler serving them. It is safe for concurrent use.
+type Processor struct {
+	namespace      string
+	buckets        []float64
+	maxLabelValues int
+
+	m      sync.Mutex
+	series map[labels]*series
+	values map[string]map[string]bool //distinct values seen per label
+}
+
+// NewProcessor returns a processor with DefaultBuckets, DefaultNamespace and
+// DefaultMaxLabelValues unless configured otherwise
+func NewProcessor(options ...Options) *Processor {
+	p := &Processor{
+		namespace:      DefaultNamespace,
+		buckets:        DefaultBuckets,
+		maxLabelValues: DefaultMaxLabelValues,
+		series:         make(map[labels]*series),
+		values:         make(map[string]map[string]bool),
+	}
+
+	for _, o := range options {
+		o(p)
+	}
+
+	return p
+}
+
+// OnFinish records the span
+func (p *Processor) OnFinish(s money.Span) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	l := labels{
+		app:     p.limit(AppLabel, s.AppName),
+		span:    p.limit(SpanLabel, s.Name),
+		code:    p.limit(CodeLabel, strconv.Itoa(s.Code)),
+		success: strconv.FormatBool(s.Success && s.Err == nil),
+	}
+
+	sr, ok := p.series[l]
+	if !ok {
+		sr = &series{
+			buckets:   make([]uint64, len(p.buckets)),
+			exemplars: make([]*exemplar, len(p.buckets)+1),
+		}
+		p.series[l] = sr
+	}
+
+	seconds := s.Duration.Seconds()
+	sr.count++
+	sr.sum += seconds
+
+	i := sort.SearchFloat64s(p.buckets, seconds)
+	if i < len(p.buckets) {
+		sr.buckets[i]++
+	}
+
+	if e := newExemplar(s, seconds); e != nil {
+		sr.exemplars[i] = e
+	}
+}
+
+// newExemplar returns the exemplar of the span, unless it has no trace context or
+// its labels exceed the OpenMetrics length limit
+func newExemplar(s money.Span, seconds float64) *exemplar {
+	if s.TC == nil {
+		return nil
+	}
+
+	e := &exemplar{
+		tid:   s.TC.TID,
+		sid:   strconv.FormatInt(s.TC.SID, 10),
+		value: seconds,
+	}
+
+	if !s.StartTime.IsZero() {
+		e.at = s.StartTime.Add(s.Duration)
+	}
+
+	if utf8.RuneCountInString(TraceIDLabel+e.tid+SpanIDLabel+e.sid) > maxExemplarRunes {
+		return nil
+	}
+
+	return e
+}
+
+// limit returns value unless the label has reached its cardinality limit
+func (p *Processor) limit(label, value string) string {
+	seen, ok := p.values[label]
+	if !ok {
+		seen = make(map[string]bool)
+		p.values[label] = seen
+	}
+
+	if seen[value] {
+		return value
+	}
+
+	if p.maxLabelValues > 0 && len(seen) >= p.maxLabelValues {
+		return OverflowValue
+	}
+
+	seen[value] = true
+	return value
+}
+
+// ServeHTTP writes all metrics in the OpenMetrics format, including exemplars, if the
+// request accepts it and in the Prometheus text exposition format otherwise
+func (p *Processor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		p.WriteOpenMetrics(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", textContentType)
+	p.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (p *Processor) WriteTo(w io.Writer) (int64, error) {
+	return p.write(w, false)
+}
+
+// WriteOpenMetrics writes all metrics, including exemplars, in the OpenMetrics text format
+func (p *Processor) WriteOpenMetrics(w io.Writer) (int64, error) {
+	return p.write(w, true)
+}
+
+func (p *Processor) write(w io.Writer, openMetrics bool) (int64, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	keys := make([]labels, 0, len(p.series))
+	for l := range p.series {
+		keys = append(keys, l)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	var (
+		cw       = &countingWriter{w: w}
+		b        = bufio.NewWriter(cw)
+		total    = p.name("spans_total")
+		family   = total
+		duration = p.name("span_duration_seconds")
+	)
+
+	//OpenMetrics names counter families without their _total suffix
+	if openMetrics {
+		family = strings.TrimSuffix(total, "_total")
+	}
+
+	fmt.Fprintf(b, "# HELP %s Number of finished spans.\n# TYPE %s counter\n", family, family)
+	for _, l := range keys {
+		fmt.Fprintf(b, "%s{%s} %d\n", total, l, p.series[l].count)
+	}
+
+	fmt.Fprintf(b, "# HELP %s Duration of finished spans.\n# TYPE %s histogram\n", duration, duration)
+	for _, l := range keys {
+		var (
+			sr         = p.series[l]
+			cumulative uint64
+		)
+
+		for i, le := range p.buckets {
+			cumulative += sr.buckets[i]
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d%s\n",
+				duration, l, formatFloat(le), cumulative, sr.exemplar(i, openMetrics))
+		}
+
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d%s\n",
+			duration, l, sr.count, sr.exemplar(len(p.buckets), openMetrics))
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", duration, l, formatFloat(sr.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", duration, l, sr.count)
+	}
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+
+	err := b.Flush()
+	return cw.n, err
+}
+
+// exemplar returns the encoded exemplar of bucket i, if any and requested
+func (sr *series) exemplar(i int, openMetrics bool) string {
+	if !openMetrics || sr.exemplars[i] == nil {
+		return ""
+	}
+
+	return sr.exemplars[i].String()
+}
+
+func (p *Processor) name(suffix string) string {
+	if p.namespace == "" {
+		return suffix
+	}
+
+	return p.namespace + "_" + suffix
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+func createSpan(app, name string, code int, success bool, duration time.Duration) money.Span {
+	return money.Span{
+		Name:     name,
+		AppName:  app,
+		TC:       &money.TraceContext{TID: "abc", PID: 1, SID: 2},
+		Code:     code,
+		Success:  success,
+		Duration: duration,
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	assert := assert.New(t)
+	p := NewProcessor(WithBuckets(0.1, 0.01))
+
+	p.OnFinish(createSpan("scytale", "ServeHTTP", 200, true, 5*time.Millisecond))
+	p.OnFinish(createSpan("scytale", "ServeHTTP", 200, true, 50*time.Millisecond))
+	p.OnFinish(createSpan("scytale", "ServeHTTP", 200, true, 500*time.Millisecond))
+
+	erred := createSpan("scytale", "fanout", 0, true, 100*time.Millisecond)
+	erred.Err = errors.New("unavailable")
+	p.OnFinish(erred)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(textContentType, w.Header().Get("Content-Type"))
+	assert.Equal(`# HELP money_spans_total Number of finished spans.
+# TYPE money_spans_total counter
+money_spans_total{app="scytale",span="ServeHTTP",code="200",success="true"} 3
+money_spans_total{app="scytale",span="fanout",code="0",success="false"} 1
+# HELP money_span_duration_seconds Duration of finished spans.
+# TYPE money_span_duration_seconds histogram
+money_span_duration_seconds_bucket{app="scytale",span="ServeHTTP",code="200",success="true",le="0.01"} 1
+money_span_duration_seconds_bucket{app="scytale",span="ServeHTTP",code="200",success="true",le="0.1"} 2
+money_span_duration_seconds_bucket{app="scytale",span="ServeHTTP",code="200",success="true",le="+Inf"} 3
+money_span_duration_seconds_sum{app="scytale",span="ServeHTTP",code="200",success="true"} 0.555
+money_span_duration_seconds_count{app="scytale",span="ServeHTTP",code="200",success="true"} 3
+money_span_duration_seconds_bucket{app="scytale",span="fanout",code="0",success="false",le="0.01"} 0
+money_span_duration_seconds_bucket{app="scytale",span="fanout",code="0",success="false",le="0.1"} 1
+money_span_duration_seconds_bucket{app="scytale",span="fanout",code="0",success="false",le="+Inf"} 1
+money_span_duration_seconds_sum{app="scytale",span="fanout",code="0",success="false"} 0.1
+money_span_duration_seconds_count{app="scytale",span="fanout",code="0",success="false"} 1
+`, w.Body.String())
+}
+
+func TestProcessorCardinality(t *testing.T) {
+	assert := assert.New(t)
+	p := NewProcessor(WithMaxLabelValues(2), WithNamespace(""), WithBuckets())
+
+	for _, name := range []string{"/devices/1", "/devices/2", "/devices/3", "/devices/1", "/devices/4"} {
+		p.OnFinish(createSpan("app", name, 200, true, time.Millisecond))
+	}
+
+	var b strings.Builder
+	_, err := p.WriteTo(&b)
+	assert.Nil(err)
+
+	assert.Contains(b.String(), `spans_total{app="app",span="/devices/1",code="200",success="true"} 2`)
+	assert.Contains(b.String(), `spans_total{app="app",span="/devices/2",code="200",success="true"} 1`)
+	assert.Contains(b.String(), `spans_total{app="app",span="other",code="200",success="true"} 2`)
+	assert.NotContains(b.String(), "/devices/3")
+}
+
+func TestEscape(t *testing.T) {
+	p := NewProcessor()
+	p.OnFinish(createSpan("a\"b", "c\\d\ne", 200, true, time.Millisecond))
+
+	var b strings.Builder
+	p.WriteTo(&b)
+	assert.Contains(t, b.String(), `{app="a\"b",span="c\\d\ne",code="200",success="true"}`)
+}
+
+func TestProcessorExemplars(t *testing.T) {
+	assert := assert.New(t)
+	p := NewProcessor(WithBuckets(0.1))
+
+	first := createSpan("scytale", "ServeHTTP", 200, true, 10*time.Millisecond)
+	second := createSpan("scytale", "ServeHTTP", 200, true, 20*time.Millisecond)
+	second.TC = &money.TraceContext{TID: "def", PID: 1, SID: 7}
+	second.StartTime = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	slow := createSpan("scytale", "ServeHTTP", 200, true, time.Second)
+	slow.TC = &money.TraceContext{TID: strings.Repeat("x", 120), PID: 1, SID: 8}
+
+	for _, s := range []money.Span{first, second, slow} {
+		p.OnFinish(s)
+	}
+
+	t.Run("OpenMetrics", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+
+		assert.Equal(openMetricsContentType, w.Header().Get("Content-Type"))
+		assert.Equal(`# HELP money_spans Number of finished spans.
+# TYPE money_spans counter
+money_spans_total{app="scytale",span="ServeHTTP",code="200",success="true"} 3
+# HELP money_span_duration_seconds Duration of finished spans.
+# TYPE money_span_duration_seconds histogram
+money_span_duration_seconds_bucket{app="scytale",span="ServeHTTP",code="200",success="true",le="0.1"} 2 # {trace_id="def",span_id="7"} 0.02 1556706600.020
+money_span_duration_seconds_bucket{app="scytale",span="ServeHTTP",code="200",success="true",le="+Inf"} 3
+money_span_duration_seconds_sum{app="scytale",span="ServeHTTP",code="200",success="true"} 1.03
+money_span_duration_seconds_count{app="scytale",span="ServeHTTP",code="200",success="true"} 3
+# EOF
+`, w.Body.String())
+	})
+
+	t.Run("Prometheus", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+		assert.Equal(textContentType, w.Header().Get("Content-Type"))
+		assert.NotContains(w.Body.String(), "trace_id")
+		assert.NotContains(w.Body.String(), "# EOF")
+	})
+}
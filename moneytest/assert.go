@@ -0,0 +1,122 @@
+package moneytest
+
+import (
+	"reflect"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// TestingT is the subset of testing.TB needed by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// FindSpan returns the first span with the given name
+func FindSpan(spans []money.Span, name string) (money.Span, bool) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return money.Span{}, false
+}
+
+func findSpan(t TestingT, spans []money.Span, name string) (money.Span, bool) {
+	t.Helper()
+
+	s, ok := FindSpan(spans, name)
+	if !ok {
+		t.Errorf("no span named %q among %v", name, spanNames(spans))
+	}
+
+	return s, ok
+}
+
+func spanNames(spans []money.Span) []string {
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+
+	return names
+}
+
+// AssertSpanNames asserts that the spans have exactly the given names, in order
+func AssertSpanNames(t TestingT, spans []money.Span, names ...string) bool {
+	t.Helper()
+
+	if actual := spanNames(spans); !reflect.DeepEqual(actual, names) {
+		t.Errorf("expected spans %v but got %v", names, actual)
+		return false
+	}
+
+	return true
+}
+
+// AssertChildOf asserts that the span named child is a direct child of the span named parent
+func AssertChildOf(t TestingT, spans []money.Span, parent, child string) bool {
+	t.Helper()
+
+	p, ok := findSpan(t, spans, parent)
+	if !ok {
+		return false
+	}
+
+	c, ok := findSpan(t, spans, child)
+	if !ok {
+		return false
+	}
+
+	if c.TC.TID != p.TC.TID || c.TC.PID != p.TC.SID || c.TC.SID == p.TC.SID {
+		t.Errorf("expected span %q (%s) to be a child of %q (%s)",
+			child, money.EncodeTraceContext(c.TC), parent, money.EncodeTraceContext(p.TC))
+		return false
+	}
+
+	return true
+}
+
+// AssertCode asserts the response code of the span with the given name
+func AssertCode(t TestingT, spans []money.Span, name string, code int) bool {
+	t.Helper()
+
+	s, ok := findSpan(t, spans, name)
+	if ok && s.Code != code {
+		t.Errorf("expected span %q to have code %d but got %d", name, code, s.Code)
+		return false
+	}
+
+	return ok
+}
+
+// AssertSuccess asserts whether the span with the given name succeeded
+func AssertSuccess(t TestingT, spans []money.Span, name string, success bool) bool {
+	t.Helper()
+
+	s, ok := findSpan(t, spans, name)
+	if ok && s.Success != success {
+		t.Errorf("expected span %q to have success %v but got %v", name, success, s.Success)
+		return false
+	}
+
+	return ok
+}
+
+// AssertAttribute asserts the value of an attribute of the span with the given name
+func AssertAttribute(t TestingT, spans []money.Span, name, key, value string) bool {
+	t.Helper()
+
+	s, ok := findSpan(t, spans, name)
+	if !ok {
+		return false
+	}
+
+	if actual, found := s.Attributes[key]; !found || actual != value {
+		t.Errorf("expected span %q to have attribute %s=%q but got %q (present: %v)", name, key, value, actual, found)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,56 @@
+package moneytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+type fakeT struct {
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	spans := []money.Span{
+		{Name: "parent", TC: &money.TraceContext{TID: "a", PID: 1, SID: 1}, Code: 200, Success: true},
+		{Name: "child", TC: &money.TraceContext{TID: "a", PID: 1, SID: 2}, Attributes: map[string]string{"k": "v"}},
+		{Name: "stranger", TC: &money.TraceContext{TID: "b", PID: 1, SID: 3}},
+	}
+
+	tests := []struct {
+		name   string
+		assert func(TestingT) bool
+		pass   bool
+	}{
+		{"NamesPass", func(t TestingT) bool { return AssertSpanNames(t, spans, "parent", "child", "stranger") }, true},
+		{"NamesFail", func(t TestingT) bool { return AssertSpanNames(t, spans, "child", "parent") }, false},
+		{"ChildOfPass", func(t TestingT) bool { return AssertChildOf(t, spans, "parent", "child") }, true},
+		{"ChildOfOtherTrace", func(t TestingT) bool { return AssertChildOf(t, spans, "parent", "stranger") }, false},
+		{"ChildOfSelf", func(t TestingT) bool { return AssertChildOf(t, spans, "parent", "parent") }, false},
+		{"ChildOfMissing", func(t TestingT) bool { return AssertChildOf(t, spans, "nobody", "child") }, false},
+		{"CodePass", func(t TestingT) bool { return AssertCode(t, spans, "parent", 200) }, true},
+		{"CodeFail", func(t TestingT) bool { return AssertCode(t, spans, "parent", 500) }, false},
+		{"CodeMissing", func(t TestingT) bool { return AssertCode(t, spans, "nobody", 200) }, false},
+		{"SuccessPass", func(t TestingT) bool { return AssertSuccess(t, spans, "child", false) }, true},
+		{"SuccessFail", func(t TestingT) bool { return AssertSuccess(t, spans, "parent", false) }, false},
+		{"AttributePass", func(t TestingT) bool { return AssertAttribute(t, spans, "child", "k", "v") }, true},
+		{"AttributeWrongValue", func(t TestingT) bool { return AssertAttribute(t, spans, "child", "k", "x") }, false},
+		{"AttributeAbsent", func(t TestingT) bool { return AssertAttribute(t, spans, "parent", "k", "v") }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := new(fakeT)
+			assert.Equal(t, test.pass, test.assert(f))
+			assert.Equal(t, test.pass, len(f.errors) == 0, f.errors)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package moneytest provides utilities to test code instrumented with golang-money:
+// a recorder of finished spans, a fake clock, deterministic span-ids, a recording
+// Spanner and assertions over recorded spans.
+//
+// Handlers wrapped by money.HTTPSpanner.Decorate can be tested by building the spanner
+// with NewHTTPSpanner:
+//
+//	rec, clock := moneytest.NewRecorder(), moneytest.NewClock(moneytest.Epoch)
+//	spanner := moneytest.NewHTTPSpanner(rec, money.WithClock(clock))
+//	server := httptest.NewServer(spanner.Decorate("app", handler))
+//	...
+//	moneytest.AssertChildOf(t, rec.Spans(), "ServeHTTP", "query")
+package moneytest
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+var _ money.Clock = (*Clock)(nil)
+
+// Epoch is the time at which clocks created by NewSpanner start
+var Epoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a fake clock which only moves when told to. It implements money.Clock
+// and is safe for concurrent use.
+type Clock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock set at start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.now
+}
+
+// Start returns the current time of the clock, such that it can be used as a money.Clock
+func (c *Clock) Start() time.Time {
+	return c.Now()
+}
+
+// End returns the time elapsed on the clock since start
+func (c *Clock) End(start time.Time) time.Duration {
+	return c.Now().Sub(start)
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Sequence generates deterministic span-ids. It is safe for concurrent use.
+type Sequence struct {
+	last int64
+}
+
+// NewSequence returns a sequence whose first id is first
+func NewSequence(first int64) *Sequence {
+	return &Sequence{last: first - 1}
+}
+
+// Next returns the next id of the sequence
+func (s *Sequence) Next() int64 {
+	return atomic.AddInt64(&s.last, 1)
+}
+
+// Recorder is a money.SpanProcessor which keeps every finished span.
+// It is safe for concurrent use.
+type Recorder struct {
+	m     sync.Mutex
+	spans []money.Span
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return new(Recorder)
+}
+
+// OnFinish records s
+func (r *Recorder) OnFinish(s money.Span) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the recorded spans in the order they finished
+func (r *Recorder) Spans() []money.Span {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	spans := make([]money.Span, len(r.spans))
+	copy(spans, r.spans)
+	return spans
+}
+
+// Reset forgets all recorded spans
+func (r *Recorder) Reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.spans = nil
+}
+
+// NewHTTPSpanner returns a money.HTTPSpanner which reports finished spans to r and
+// numbers child spans sequentially from 1. Further options are applied afterwards.
+func NewHTTPSpanner(r *Recorder, options ...money.HTTPSpannerOptions) *money.HTTPSpanner {
+	defaults := []money.HTTPSpannerOptions{
+		money.WithSpanProcessors(r),
+		money.WithSpanIDGenerator(NewSequence(1).Next),
+	}
+
+	return money.NewHTTPSpanner(append(defaults, options...)...)
+}
@@ -0,0 +1,93 @@
+package moneytest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	money "github.com/xmidt-org/golang-money"
+)
+
+func TestClock(t *testing.T) {
+	c := NewClock(Epoch)
+	start := c.Start()
+	c.Advance(time.Second)
+	assert.Equal(t, Epoch.Add(time.Second), c.Now())
+	assert.Equal(t, time.Second, c.End(start))
+}
+
+func TestSequence(t *testing.T) {
+	var (
+		s  = NewSequence(5)
+		wg sync.WaitGroup
+	)
+
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			s.Next()
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int64(15), s.Next())
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	r.OnFinish(money.Span{Name: "one"})
+	assert.Len(t, r.Spans(), 1)
+
+	r.Reset()
+	assert.Empty(t, r.Spans())
+}
+
+func TestNewHTTPSpanner(t *testing.T) {
+	var (
+		require = require.New(t)
+		rec     = NewRecorder()
+		clock   = NewClock(Epoch)
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker, ok := money.TrackerFromContext(r.Context())
+		require.True(ok)
+
+		child := tracker.Start(r.Context(), money.Span{})
+		clock.Advance(time.Millisecond)
+		child.Finish(money.Result{Name: "query", AppName: "test-app", Success: true, Attributes: map[string]string{"table": "devices"}})
+
+		w.WriteHeader(http.StatusAccepted)
+		tracker.Finish(money.Result{Name: "ServeHTTP", AppName: "test-app", Code: http.StatusAccepted, Success: true})
+	})
+
+	server := httptest.NewServer(NewHTTPSpanner(rec, money.WithClock(clock)).Decorate("test-app", handler))
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL, nil)
+	require.Nil(err)
+	request.Header.Set(money.MoneyHeader, "trace-id=abc;parent-id=100;span-id=100")
+
+	response, err := server.Client().Do(request)
+	require.Nil(err)
+	io.ReadAll(response.Body)
+	response.Body.Close()
+
+	spans := rec.Spans()
+	AssertSpanNames(t, spans, "query", "ServeHTTP")
+	AssertChildOf(t, spans, "ServeHTTP", "query")
+	AssertCode(t, spans, "ServeHTTP", http.StatusAccepted)
+	AssertSuccess(t, spans, "query", true)
+	AssertAttribute(t, spans, "query", "table", "devices")
+
+	query, _ := FindSpan(spans, "query")
+	assert.Equal(t, money.TraceContext{TID: "abc", PID: 100, SID: 1}, *query.TC)
+	assert.Equal(t, Epoch, query.StartTime)
+	assert.Equal(t, time.Millisecond, query.Duration)
+}
@@ -0,0 +1,171 @@
+package moneytest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	money "github.com/xmidt-org/golang-money"
+)
+
+// Spanner is a money.Spanner whose spans are fully deterministic: times come from
+// its Clock, span-ids from its IDs and finished spans are kept by its Recorder.
+type Spanner struct {
+	Clock    *Clock
+	IDs      *Sequence
+	Recorder *Recorder
+
+	// Host is set on every finished span
+	Host string
+}
+
+// NewSpanner returns a spanner with a clock set at Epoch, ids starting at 1 and an empty recorder
+func NewSpanner() *Spanner {
+	return &Spanner{
+		Clock:    NewClock(Epoch),
+		IDs:      NewSequence(1),
+		Recorder: NewRecorder(),
+		Host:     "localhost",
+	}
+}
+
+// Start starts the span s at the current time of the clock. Spans without a trace
+// context start a new trace whose trace-id is derived from their span-id.
+func (sp *Spanner) Start(_ context.Context, s money.Span) money.Tracker {
+	if s.TC == nil {
+		id := sp.IDs.Next()
+		s.TC = &money.TraceContext{TID: fmt.Sprintf("trace-%d", id), PID: id, SID: id}
+	}
+
+	s.StartTime = sp.Clock.Now()
+	return &Tracker{spanner: sp, span: s}
+}
+
+// Tracker is the money.Tracker of a Spanner
+type Tracker struct {
+	spanner *Spanner
+
+	m     sync.Mutex
+	span  money.Span
+	spans []string
+	done  bool
+}
+
+// Start starts a child span of the tracked span, or returns nil once it has finished
+func (t *Tracker) Start(ctx context.Context, s money.Span) money.Tracker {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.done {
+		return nil
+	}
+
+	s.TC = &money.TraceContext{TID: t.span.TC.TID, PID: t.span.TC.SID, SID: t.spanner.IDs.Next()}
+	return t.spanner.Start(ctx, s)
+}
+
+// Finish concludes the span as money.HTTPTracker does and records it
+func (t *Tracker) Finish(r money.Result) {
+	t.m.Lock()
+	if t.done {
+		t.m.Unlock()
+		return
+	}
+
+	t.span.Duration = t.spanner.Clock.Now().Sub(t.span.StartTime)
+	t.span.Host = t.spanner.Host
+	if r.Name != "" {
+		t.span.Name = r.Name
+	}
+
+	if r.AppName != "" {
+		t.span.AppName = r.AppName
+	}
+
+	t.span.Code, t.span.Err, t.span.Success = r.Code, r.Err, r.Success
+
+	if len(r.Attributes) > 0 {
+		attributes := make(map[string]string, len(t.span.Attributes)+len(r.Attributes))
+		for k, v := range t.span.Attributes {
+			attributes[k] = v
+		}
+
+		for k, v := range r.Attributes {
+			attributes[k] = v
+		}
+
+		t.span.Attributes = attributes
+	}
+
+	if len(r.Links) > 0 {
+		t.span.Links = append(append([]money.Link(nil), t.span.Links...), r.Links...)
+	}
+
+	t.spans = append(t.spans, t.span.String())
+	t.done = true
+	s := t.span
+	t.m.Unlock()
+
+	t.spanner.Recorder.OnFinish(s)
+}
+
+// String returns the encoded span once finished, the empty string otherwise
+func (t *Tracker) String() string {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if !t.done {
+		return ""
+	}
+
+	return t.span.String()
+}
+
+// DecorateTransactor injects the trace context of the tracked span into outgoing requests
+// and collects the spans returned in their X-MoneySpans headers
+func (t *Tracker) DecorateTransactor(transactor money.Transactor, options ...money.SpanForwardingOptions) money.Transactor {
+	return func(r *http.Request) (*http.Response, error) {
+		t.m.Lock()
+		r.Header.Add(money.MoneyHeader, money.EncodeTraceContext(t.span.TC))
+		t.m.Unlock()
+
+		resp, err := transactor(r)
+		if err != nil {
+			return resp, err
+		}
+
+		t.m.Lock()
+		defer t.m.Unlock()
+
+		t.spans = append(t.spans, resp.Header[http.CanonicalHeaderKey(money.MoneySpansHeader)]...)
+		for _, o := range options {
+			t.spans = append(t.spans, o(resp)...)
+		}
+
+		return resp, nil
+	}
+}
+
+// Spans returns the encoded spans collected by this tracker once its span has finished
+func (t *Tracker) Spans() []string {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if !t.done {
+		return nil
+	}
+
+	spans := make([]string, len(t.spans))
+	copy(spans, t.spans)
+	return spans
+}
+
+// TraceContext returns a copy of the trace context of the tracked span
+func (t *Tracker) TraceContext() *money.TraceContext {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	tc := *t.span.TC
+	return &tc
+}
@@ -0,0 +1,79 @@
+package moneytest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	money "github.com/xmidt-org/golang-money"
+)
+
+func TestSpanner(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		spanner = NewSpanner()
+		ctx     = context.Background()
+	)
+
+	root := spanner.Start(ctx, money.Span{})
+	spanner.Clock.Advance(10 * time.Millisecond)
+
+	child := root.Start(ctx, money.Span{})
+	spanner.Clock.Advance(20 * time.Millisecond)
+	child.Finish(money.Result{Name: "child", AppName: "test-app", Success: true})
+
+	assert.Empty(root.String())
+	assert.Nil(root.Spans())
+
+	spanner.Clock.Advance(5 * time.Millisecond)
+	root.Finish(money.Result{Name: "root", AppName: "test-app", Code: 200, Success: true})
+	root.Finish(money.Result{Name: "again"})
+
+	assert.Nil(root.Start(ctx, money.Span{}))
+
+	spans := spanner.Recorder.Spans()
+	AssertSpanNames(t, spans, "child", "root")
+	AssertChildOf(t, spans, "root", "child")
+
+	assert.Equal("span-name=root;app-name=test-app;span-duration=35000000ns;span-success=true"+
+		";parent-id=1;span-id=1;trace-id=trace-1;start-time=2019-01-01T00:00:00Z;host=localhost;response-code=200",
+		root.String())
+	assert.Equal("span-name=child;app-name=test-app;span-duration=20000000ns;span-success=true"+
+		";parent-id=1;span-id=2;trace-id=trace-1;start-time=2019-01-01T00:00:00.01Z;host=localhost",
+		spans[0].String())
+	assert.Equal([]string{root.String()}, root.Spans())
+}
+
+func TestSpannerDecorateTransactor(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		spanner = NewSpanner()
+		tracker = spanner.Start(context.Background(), money.Span{})
+	)
+
+	transactor := tracker.DecorateTransactor(func(r *http.Request) (*http.Response, error) {
+		assert.Equal("parent-id=1;span-id=1;trace-id=trace-1", r.Header.Get(money.MoneyHeader))
+
+		response := &http.Response{Header: make(http.Header)}
+		response.Header.Add(money.MoneySpansHeader, "remote-span")
+		return response, nil
+	}, func(*http.Response) []string { return []string{"forwarded-span"} })
+
+	r, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := transactor(r)
+	assert.Nil(err)
+
+	tracker.Finish(money.Result{Name: "root"})
+	assert.Equal([]string{"remote-span", "forwarded-span", tracker.String()}, tracker.Spans())
+}
+
+func TestSpannerTraceContext(t *testing.T) {
+	tracker := NewSpanner().Start(context.Background(), money.Span{}).(money.TraceContextTracker)
+	tc := tracker.TraceContext()
+	assert.Equal(t, money.TraceContext{TID: "trace-1", PID: 1, SID: 1}, *tc)
+
+	tc.SID = 5
+	assert.Equal(t, int64(1), tracker.TraceContext().SID)
+}
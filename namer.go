@@ -0,0 +1,79 @@
+package money
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// defaultSpanName is the name of server spans in Decorate without WithSpanNamer
+const defaultSpanName = "ServeHTTP"
+
+// Path segment placeholders of NormalizePath
+const (
+	uuidPlaceholder = "{uuid}"
+	macPlaceholder  = "{mac}"
+	idPlaceholder   = "{id}"
+)
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	macSegment  = regexp.MustCompile(`^(?i:mac:)?[0-9a-fA-F]{2}([:-]?[0-9a-fA-F]{2}){5}$`)
+	idSegment   = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{16,})$`)
+)
+
+// SpanNamer names the server span Decorate starts for a request
+type SpanNamer func(*http.Request) string
+
+// MethodPath names spans by the method and path of the request, i.e. "GET /api/v2/devices".
+// Paths with IDs make for unbounded names, see NormalizedMethodPath. The path is taken
+// as the client sent it; Span.String escapes it, so it cannot add fields to the span.
+func MethodPath(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// NormalizedMethodPath names spans as MethodPath does with the path passed through
+// NormalizePath, i.e. "GET /api/v2/device/{mac}/stat"
+func NormalizedMethodPath(r *http.Request) string {
+	return r.Method + " " + NormalizePath(r.URL.Path)
+}
+
+// PatternNamer names spans by the ServeMux pattern matching the request, i.e. "GET /devices/{id}".
+// The pattern is taken from Request.Pattern, which is set when Decorate wraps a handler
+// registered on a ServeMux, or else looked up on mux, which may be nil, when Decorate
+// wraps the ServeMux itself. Requests without pattern are named "ServeHTTP".
+func PatternNamer(mux *http.ServeMux) SpanNamer {
+	return func(r *http.Request) string {
+		if r.Pattern != "" {
+			return r.Pattern
+		}
+
+		if mux != nil {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				return pattern
+			}
+		}
+
+		return defaultSpanName
+	}
+}
+
+// NormalizePath replaces the path segments which are UUIDs, MAC addresses (optionally
+// prefixed by "mac:" as in device ids), decimal numbers or long hexadecimal ids by
+// {uuid}, {mac} and {id} placeholders
+func NormalizePath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		switch {
+		case uuidSegment.MatchString(s):
+			segments[i] = uuidPlaceholder
+		case macSegment.MatchString(s):
+			segments[i] = macPlaceholder
+		case idSegment.MatchString(s):
+			segments[i] = idPlaceholder
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,102 @@
+package money
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/api/v2/device/mac:112233445566/stat":         "/api/v2/device/{mac}/stat",
+		"/api/v2/device/MAC:11:22:33:44:55:66/stat":    "/api/v2/device/{mac}/stat",
+		"/devices/11-22-33-44-55-66":                   "/devices/{mac}",
+		"/traces/de305d54-75b4-431b-adb2-eb6b9e546013": "/traces/{uuid}",
+		"/users/12345/orders/7":                        "/users/{id}/orders/{id}",
+		"/objects/0123456789abcdef0123":                "/objects/{id}",
+		"/api/v2/hooks":                                "/api/v2/hooks",
+		"/api/cafe":                                    "/api/cafe",
+		"/":                                            "/",
+	} {
+		assert.Equal(t, expected, NormalizePath(path), path)
+	}
+}
+
+func TestSpanNamers(t *testing.T) {
+	r := httptest.NewRequest("GET", "/devices/mac:112233445566?fields=all", nil)
+
+	assert.Equal(t, "GET /devices/mac:112233445566", MethodPath(r))
+	assert.Equal(t, "GET /devices/{mac}", NormalizedMethodPath(r))
+
+	t.Run("Pattern", func(t *testing.T) {
+		assert := assert.New(t)
+		mux := http.NewServeMux()
+		mux.Handle("GET /devices/{id}", http.NotFoundHandler())
+
+		assert.Equal("GET /devices/{id}", PatternNamer(mux)(r))
+		assert.Equal(defaultSpanName, PatternNamer(nil)(r))
+		assert.Equal(defaultSpanName, PatternNamer(mux)(httptest.NewRequest("POST", "/hooks", nil)))
+
+		routed := httptest.NewRequest("GET", "/x", nil)
+		routed.Pattern = "GET /x"
+		assert.Equal("GET /x", PatternNamer(nil)(routed))
+	})
+}
+
+func TestDecorateSpanNamer(t *testing.T) {
+	var names []string
+
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker, _ := TrackerFromContext(r.Context())
+		tracker.Finish(Result{})
+		s, _ := ParseSpan(tracker.String())
+		names = append(names, s.Name)
+	})
+
+	serve := func(h http.Handler, path string) {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Add(MoneyHeader, "trace-id=abc;parent-id=1;span-id=1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(NewHTTPSpanner().Decorate("test", record), "/devices/1")
+	serve(NewHTTPSpanner(WithSpanNamer(NormalizedMethodPath)).Decorate("test", record), "/devices/1")
+
+	// decorating a handler registered on a mux
+	mux := http.NewServeMux()
+	mux.Handle("GET /devices/{id}", NewHTTPSpanner(WithSpanNamer(PatternNamer(nil))).Decorate("test", record))
+	serve(mux, "/devices/2")
+
+	// decorating the mux itself
+	mux = http.NewServeMux()
+	mux.Handle("GET /hooks/{id}", record)
+	serve(NewHTTPSpanner(WithSpanNamer(PatternNamer(mux))).Decorate("test", mux), "/hooks/3")
+
+	assert.Equal(t, []string{"ServeHTTP", "GET /devices/{id}", "GET /devices/{id}", "GET /hooks/{id}"}, names)
+}
+
+func TestDecorateSpanNamerInjection(t *testing.T) {
+	assert := assert.New(t)
+	var spans []string
+
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker, _ := TrackerFromContext(r.Context())
+		tracker.Finish(Result{})
+		spans = tracker.(*HTTPTracker).Spans()
+	})
+
+	path := "/devices/x;err=forged;links=trace-id=victim&span-id=9"
+	r := httptest.NewRequest("GET", path, nil)
+	r.Header.Add(MoneyHeader, "trace-id=abc;parent-id=1;span-id=1")
+	NewHTTPSpanner(WithSpanNamer(MethodPath)).Decorate("test", record).ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Len(spans, 1)
+	s, err := ParseSpan(spans[0])
+	assert.Nil(err)
+	assert.Equal("GET "+path, s.Name)
+	assert.Nil(s.Err)
+	assert.Empty(s.Links)
+	assert.Empty(s.Attributes)
+}
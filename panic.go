@@ -0,0 +1,65 @@
+package money
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// panicStackKey is the attribute holding the stack trace of a recovered panic
+const panicStackKey = "panic-stack"
+
+// PanicMode selects what Decorate does once it recovered from a panic and finished the span
+type PanicMode int
+
+const (
+	// RePanic resumes the panic, such that the server or an outer middleware handles it
+	RePanic PanicMode = iota + 1
+
+	// WriteServerError answers the request with a 500 unless the handler already wrote a response
+	WriteServerError
+)
+
+// PanicError is the Err of a span whose handler panicked
+type PanicError struct {
+	// Value is the value passed to panic
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic finishes the tracker as failed if the handler panicked. It must be deferred.
+// http.ErrAbortHandler is always resumed since it is how handlers abort a response on purpose.
+func (hs *HTTPSpanner) recoverPanic(tracker Tracker, rw *simpleResponseWriter) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	r := Result{
+		Code: http.StatusInternalServerError,
+		Err:  &PanicError{Value: v},
+	}
+
+	if hs.panicStack {
+		r.Attributes = map[string]string{panicStackKey: string(debug.Stack())}
+	}
+
+	tracker.Finish(r)
+
+	if hs.panicMode == RePanic || v == http.ErrAbortHandler {
+		panic(v)
+	}
+
+	if !rw.wroteHeader {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,15 @@
+package money
+
+// SpanProcessor is notified of every span finished by the trackers of an HTTPSpanner.
+// Processors are called synchronously from Tracker.Finish and must not modify the span.
+type SpanProcessor interface {
+	OnFinish(Span)
+}
+
+// SpanProcessorFunc is a function adapter for SpanProcessor
+type SpanProcessorFunc func(Span)
+
+// OnFinish calls f(s)
+func (f SpanProcessorFunc) OnFinish(s Span) {
+	f(s)
+}
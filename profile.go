@@ -0,0 +1,77 @@
+package money
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+// defaultTaskType names the runtime/trace tasks of spans which have no name when started
+const defaultTaskType = "money-span"
+
+// profiling holds what a tracker applied to its goroutine under WithProfilerLabels
+type profiling struct {
+	// ctx carries the pprof labels and the runtime/trace task of the span
+	ctx context.Context
+
+	// previous carries the labels to restore once the span finishes. The labels of a
+	// goroutine cannot be read, so these are the labels of the context the span was
+	// started with, as pprof.Do restores them.
+	previous context.Context
+
+	// scoped labels are restored by whoever applied them, that is Decorate once the
+	// handler returned, rather than by Finish
+	scoped bool
+
+	task *trace.Task
+	once sync.Once
+}
+
+// startProfiling labels the calling goroutine with the trace-id, span name and app name
+// of s and starts a runtime/trace task named after the span
+func startProfiling(ctx context.Context, s Span) *profiling {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var labels []string
+	if s.TC != nil {
+		labels = append(labels, tIDKey, s.TC.TID)
+	}
+
+	if s.Name != "" {
+		labels = append(labels, spanNameKey, s.Name)
+	}
+
+	if s.AppName != "" {
+		labels = append(labels, appNameKey, s.AppName)
+	}
+
+	p := &profiling{previous: ctx}
+	p.ctx = pprof.WithLabels(ctx, pprof.Labels(labels...))
+	pprof.SetGoroutineLabels(p.ctx)
+
+	taskType := s.Name
+	if taskType == "" {
+		taskType = defaultTaskType
+	}
+
+	p.ctx, p.task = trace.NewTask(p.ctx, taskType)
+	if s.TC != nil {
+		trace.Log(p.ctx, tIDKey, s.TC.TID)
+	}
+
+	return p
+}
+
+// endTask ends the task of the span. It may be called from any goroutine, and repeatedly.
+func (p *profiling) endTask() {
+	p.once.Do(p.task.End)
+}
+
+// restore restores the labels the goroutine had before the span started.
+// It affects the goroutine calling it, which is expected to be the one which started the span.
+func (p *profiling) restore() {
+	pprof.SetGoroutineLabels(p.previous)
+}
@@ -0,0 +1,113 @@
+package money
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineLabelled reports whether any goroutine carries the label key with value
+func goroutineLabelled(t *testing.T, key, value string) bool {
+	var b bytes.Buffer
+	require.Nil(t, pprof.Lookup("goroutine").WriteTo(&b, 1))
+	return strings.Contains(b.String(), `"`+key+`":"`+value+`"`)
+}
+
+func TestProfilerLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	// tasks are only recorded while tracing, which go test -trace may have started already
+	if !trace.IsEnabled() {
+		var b bytes.Buffer
+		require.Nil(t, trace.Start(&b))
+		defer trace.Stop()
+	}
+
+	spanner := NewHTTPSpanner(WithProfilerLabels())
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("worker", "batch"))
+	pprof.SetGoroutineLabels(ctx)
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	tracker := spanner.Start(ctx, Span{Name: "consume", AppName: "test-app", TC: &TraceContext{TID: "profiled-abc", PID: 1, SID: 1}})
+	labelled := tracker.(*HTTPTracker).profiling.ctx
+
+	for k, v := range map[string]string{tIDKey: "profiled-abc", spanNameKey: "consume", appNameKey: "test-app", "worker": "batch"} {
+		value, _ := pprof.Label(labelled, k)
+		assert.Equal(v, value, k)
+	}
+
+	assert.True(goroutineLabelled(t, tIDKey, "profiled-abc"))
+
+	tracker.Finish(Result{})
+	assert.False(goroutineLabelled(t, tIDKey, "profiled-abc"))
+	assert.True(goroutineLabelled(t, "worker", "batch"))
+}
+
+func TestProfilerLabelsDisabled(t *testing.T) {
+	tracker := NewHTTPSpanner().Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 1}})
+	assert.Nil(t, tracker.(*HTTPTracker).profiling)
+}
+
+func TestDecorateProfilerLabels(t *testing.T) {
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	serve := func(tid string, handler func(Tracker)) (label string) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Add(MoneyHeader, "trace-id="+tid+";parent-id=1;span-id=1")
+
+		NewHTTPSpanner(WithProfilerLabels()).Decorate("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			label, _ = pprof.Label(r.Context(), spanNameKey)
+
+			tracker, ok := TrackerFromContext(r.Context())
+			require.True(t, ok)
+			handler(tracker)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+
+		return
+	}
+
+	t.Run("Finished", func(t *testing.T) {
+		assert.Equal(t, "ServeHTTP", serve("profiled-finished", func(tracker Tracker) { tracker.Finish(Result{}) }))
+		assert.False(t, goroutineLabelled(t, tIDKey, "profiled-finished"))
+	})
+
+	t.Run("Unfinished", func(t *testing.T) {
+		var tracker Tracker
+		serve("profiled-unfinished", func(tr Tracker) { tracker = tr })
+		assert.False(t, goroutineLabelled(t, tIDKey, "profiled-unfinished"))
+
+		// finishing elsewhere does not touch the labels of that goroutine
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("worker", "batch")))
+		tracker.Finish(Result{})
+		assert.True(t, goroutineLabelled(t, "worker", "batch"))
+	})
+}
+
+func TestAbandonedProfilerLabels(t *testing.T) {
+	assert := assert.New(t)
+	finished := make(chan Span, 1)
+
+	spanner := NewHTTPSpanner(WithProfilerLabels(), WithAbandonedSpans(),
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished <- s })))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	tracker := spanner.Start(ctx, Span{Name: "consume", TC: &TraceContext{TID: "profiled-abandoned", PID: 1, SID: 1}})
+	cancel()
+	<-finished
+
+	// the labels belong to this goroutine, which restores them on Finish
+	assert.True(goroutineLabelled(t, tIDKey, "profiled-abandoned"))
+	tracker.Finish(Result{})
+	assert.False(goroutineLabelled(t, tIDKey, "profiled-abandoned"))
+}
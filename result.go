@@ -0,0 +1,118 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// StatusClientClosedRequest is the non-standard code recorded for spans whose
+// context was canceled, i.e. because the client went away
+const StatusClientClosedRequest = 499
+
+// SuccessPolicy decides whether a span which ended with the given code and error succeeded
+type SuccessPolicy func(code int, err error) bool
+
+// DefaultSuccessPolicy is the policy of an HTTPSpanner without WithSuccessPolicy:
+// a span succeeds when it has no error and its code is below 400
+func DefaultSuccessPolicy(code int, err error) bool {
+	return err == nil && code < http.StatusBadRequest
+}
+
+// ServerErrorPolicy is a SuccessPolicy which only fails spans with an error or a
+// code of 500 and above, such that client errors like 404 count as success
+func ServerErrorPolicy(code int, err error) bool {
+	return err == nil && code < http.StatusInternalServerError
+}
+
+// IgnoreErrors returns a policy treating errors which match any of the targets,
+// as reported by errors.Is, as no error at all before consulting p.
+// For example, IgnoreErrors(DefaultSuccessPolicy, context.Canceled) does not fail
+// spans whose client went away.
+func IgnoreErrors(p SuccessPolicy, targets ...error) SuccessPolicy {
+	return func(code int, err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return p(code, nil)
+			}
+		}
+
+		return p(code, err)
+	}
+}
+
+// Result builds the result of a span which ended with the given code and error.
+// When code is 0, it is derived from the error through ErrorCode.
+func (p SuccessPolicy) Result(code int, err error) Result {
+	if code == 0 {
+		code = ErrorCode(err)
+	}
+
+	return Result{
+		Code:    code,
+		Err:     err,
+		Success: p(code, err),
+	}
+}
+
+// ResponseResult builds the result of an outbound span from the output of a Transactor
+func (p SuccessPolicy) ResponseResult(resp *http.Response, err error) Result {
+	var code int
+	if resp != nil {
+		code = resp.StatusCode
+	}
+
+	return p.Result(code, err)
+}
+
+// TrackerResult builds the result of the span of t from an HTTP status and an error.
+// It applies the success policy of the spanner of an HTTPTracker and DefaultSuccessPolicy
+// for any other Tracker, such that code holding a Tracker need not know its type.
+func TrackerResult(t Tracker, code int, err error) Result {
+	return trackerPolicy(t).Result(code, err)
+}
+
+// TrackerResponseResult builds the result of the outbound span of t from the output of
+// a Transactor under the same policy as TrackerResult
+func TrackerResponseResult(t Tracker, resp *http.Response, err error) Result {
+	return trackerPolicy(t).ResponseResult(resp, err)
+}
+
+func trackerPolicy(t Tracker) SuccessPolicy {
+	if ht, ok := t.(*HTTPTracker); ok && ht.hs != nil {
+		return ht.hs.Policy()
+	}
+
+	return DefaultSuccessPolicy
+}
+
+// StatusResult builds a Result from an HTTP status under DefaultSuccessPolicy
+func StatusResult(code int) Result {
+	return SuccessPolicy(DefaultSuccessPolicy).Result(code, nil)
+}
+
+// ErrorResult builds a Result from an error under DefaultSuccessPolicy
+func ErrorResult(err error) Result {
+	return SuccessPolicy(DefaultSuccessPolicy).Result(0, err)
+}
+
+// NewResult builds a Result from an HTTP status and an error under DefaultSuccessPolicy
+func NewResult(code int, err error) Result {
+	return SuccessPolicy(DefaultSuccessPolicy).Result(code, err)
+}
+
+// ErrorCode returns the code recorded for a span which ended with err and no code:
+// StatusClientClosedRequest for context.Canceled, 504 for context.DeadlineExceeded,
+// 500 for any other error and 0 without error
+func ErrorCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
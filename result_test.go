@@ -0,0 +1,78 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuccessPolicies(t *testing.T) {
+	var (
+		failure  = errors.New("failure")
+		canceled = fmt.Errorf("reading body: %w", context.Canceled)
+		ignoring = IgnoreErrors(DefaultSuccessPolicy, context.Canceled)
+	)
+
+	tests := []struct {
+		name    string
+		policy  SuccessPolicy
+		code    int
+		err     error
+		success bool
+	}{
+		{"DefaultOK", DefaultSuccessPolicy, http.StatusOK, nil, true},
+		{"DefaultNotFound", DefaultSuccessPolicy, http.StatusNotFound, nil, false},
+		{"DefaultError", DefaultSuccessPolicy, 0, failure, false},
+		{"ServerErrorNotFound", ServerErrorPolicy, http.StatusNotFound, nil, true},
+		{"ServerErrorUnavailable", ServerErrorPolicy, http.StatusServiceUnavailable, nil, false},
+		{"ServerErrorError", ServerErrorPolicy, http.StatusOK, failure, false},
+		{"IgnoredCanceled", ignoring, http.StatusOK, canceled, true},
+		{"IgnoredCanceledCode", ignoring, StatusClientClosedRequest, canceled, false},
+		{"IgnoredOther", ignoring, http.StatusOK, failure, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.success, test.policy(test.code, test.err))
+		})
+	}
+}
+
+func TestResultHelpers(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Result{Code: http.StatusOK, Success: true}, StatusResult(http.StatusOK))
+	assert.Equal(Result{Code: http.StatusNotFound}, StatusResult(http.StatusNotFound))
+	assert.Equal(Result{Success: true}, ErrorResult(nil))
+
+	deadline := fmt.Errorf("calling talaria: %w", context.DeadlineExceeded)
+	assert.Equal(Result{Code: http.StatusGatewayTimeout, Err: deadline}, ErrorResult(deadline))
+	assert.Equal(StatusClientClosedRequest, ErrorResult(context.Canceled).Code)
+	assert.Equal(http.StatusInternalServerError, ErrorResult(errors.New("failure")).Code)
+
+	failure := errors.New("failure")
+	assert.Equal(Result{Code: http.StatusOK, Err: failure}, NewResult(http.StatusOK, failure))
+
+	policy := SuccessPolicy(ServerErrorPolicy)
+	assert.Equal(Result{Code: http.StatusNotFound, Success: true}, policy.ResponseResult(&http.Response{StatusCode: http.StatusNotFound}, nil))
+	assert.Equal(Result{Code: http.StatusInternalServerError, Err: failure}, policy.ResponseResult(nil, failure))
+}
+
+func TestTrackerResult(t *testing.T) {
+	assert := assert.New(t)
+	notFound := &http.Response{StatusCode: http.StatusNotFound}
+
+	spanner := NewHTTPSpanner(WithSuccessPolicy(ServerErrorPolicy))
+	tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 1}})
+	assert.Equal(Result{Code: http.StatusNotFound, Success: true}, TrackerResult(tracker, http.StatusNotFound, nil))
+	assert.Equal(Result{Code: http.StatusNotFound, Success: true}, TrackerResponseResult(tracker, notFound, nil))
+
+	// other trackers fall back to the default policy
+	other := plainTracker{tracker}
+	assert.Equal(Result{Code: http.StatusNotFound}, TrackerResult(other, http.StatusNotFound, nil))
+	assert.Equal(Result{Code: http.StatusNotFound}, TrackerResponseResult(other, notFound, nil))
+}
@@ -0,0 +1,312 @@
+package money
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of a TailSampler
+const (
+	DefaultDecisionWait     = 10 * time.Second
+	DefaultSampleRate       = 0.01
+	DefaultMaxTraces        = 10000
+	DefaultMaxSpansPerTrace = 1000
+)
+
+// TailSamplerOptions configure a TailSampler
+type TailSamplerOptions func(*TailSampler)
+
+// WithDecisionWait sets how long the spans of a trace are buffered, counting from its
+// first span, before the sampler decides whether the trace is kept
+func WithDecisionWait(d time.Duration) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.wait = d
+	}
+}
+
+// WithSampleRate sets the fraction, between 0 and 1, of traces kept although none of
+// their spans is failed or slow. The decision is a hash of the trace-id so every
+// sampler with the same rate keeps the same traces.
+func WithSampleRate(rate float64) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.rate = rate
+	}
+}
+
+// WithLatencyThreshold keeps every trace with a span lasting longer than d.
+// It is disabled by default.
+func WithLatencyThreshold(d time.Duration) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.threshold = d
+	}
+}
+
+// WithCodePolicy sets which response codes keep a trace, server errors by default
+func WithCodePolicy(keep func(code int) bool) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.keepCode = keep
+	}
+}
+
+// WithMaxTraces bounds the number of traces buffered at once. When a new trace arrives
+// at the bound, the oldest buffered trace is decided early.
+func WithMaxTraces(n int) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.maxTraces = n
+	}
+}
+
+// WithMaxSpansPerTrace bounds the number of spans buffered for a trace, further spans
+// of the trace are dropped
+func WithMaxSpansPerTrace(n int) TailSamplerOptions {
+	return func(ts *TailSampler) {
+		ts.maxSpans = n
+	}
+}
+
+// pendingTrace holds the spans of a trace waiting for its decision
+type pendingTrace struct {
+	first time.Time
+	spans []Span
+	keep  bool
+}
+
+// decision is remembered for a decided trace so its late spans follow it
+type decision struct {
+	at   time.Time
+	keep bool
+}
+
+// TailSampler is a SpanProcessor which buffers spans per trace-id and forwards complete
+// traces to the next processor once their decision window passed. A trace is kept when
+// any of its spans failed, carries an error, has a response code matching the code policy
+// or exceeds the latency threshold; otherwise it is kept at the sample rate.
+// Spans of a trace arriving after its decision follow that decision.
+type TailSampler struct {
+	next SpanProcessor
+
+	wait      time.Duration
+	rate      float64
+	threshold time.Duration
+	keepCode  func(int) bool
+	maxTraces int
+	maxSpans  int
+	now       func() time.Time
+
+	m       sync.Mutex
+	pending map[string]*pendingTrace
+	order   []string //trace-ids of pending, oldest first
+	decided map[string]decision
+
+	dropped uint64
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewTailSampler returns a running sampler forwarding kept spans to next.
+// Close must be called to decide the traces still buffered.
+func NewTailSampler(next SpanProcessor, options ...TailSamplerOptions) *TailSampler {
+	ts := newTailSampler(next, options...)
+	go ts.run()
+	return ts
+}
+
+func newTailSampler(next SpanProcessor, options ...TailSamplerOptions) *TailSampler {
+	ts := &TailSampler{
+		next:      next,
+		wait:      DefaultDecisionWait,
+		rate:      DefaultSampleRate,
+		keepCode:  func(code int) bool { return code >= http.StatusInternalServerError },
+		maxTraces: DefaultMaxTraces,
+		maxSpans:  DefaultMaxSpansPerTrace,
+		now:       time.Now,
+		pending:   make(map[string]*pendingTrace),
+		decided:   make(map[string]decision),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	for _, o := range options {
+		o(ts)
+	}
+
+	return ts
+}
+
+// OnFinish buffers the span until the decision on its trace
+func (ts *TailSampler) OnFinish(s Span) {
+	if s.TC == nil {
+		if ts.interesting(s) {
+			ts.next.OnFinish(s)
+		}
+		return
+	}
+
+	var forward []Span
+
+	ts.m.Lock()
+	switch d, ok := ts.decided[s.TC.TID]; {
+	case ts.isClosed():
+		atomic.AddUint64(&ts.dropped, 1)
+	case ok:
+		if d.keep {
+			forward = append(forward, s)
+		}
+	default:
+		forward = ts.buffer(s)
+	}
+	ts.m.Unlock()
+
+	ts.forward(forward)
+}
+
+// Dropped returns the number of spans dropped because their trace reached
+// the span bound or the sampler was closed
+func (ts *TailSampler) Dropped() uint64 {
+	return atomic.LoadUint64(&ts.dropped)
+}
+
+// Close decides all buffered traces, forwarding the kept ones, and stops the sampler
+func (ts *TailSampler) Close() {
+	ts.once.Do(func() {
+		close(ts.done)
+	})
+
+	<-ts.stopped
+}
+
+func (ts *TailSampler) isClosed() bool {
+	select {
+	case <-ts.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ts *TailSampler) run() {
+	defer close(ts.stopped)
+
+	tick := ts.wait / 4
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ts.sweep(ts.now())
+		case <-ts.done:
+			ts.sweep(time.Time{})
+			return
+		}
+	}
+}
+
+// buffer adds the span to its pending trace, deciding the oldest trace when the
+// trace bound is reached. It returns the spans to forward and must be called under lock.
+func (ts *TailSampler) buffer(s Span) (forward []Span) {
+	p, ok := ts.pending[s.TC.TID]
+	if !ok {
+		if len(ts.order) >= ts.maxTraces && len(ts.order) > 0 {
+			forward = ts.decide(ts.order[0], ts.now())
+			ts.order = ts.order[1:]
+		}
+
+		p = &pendingTrace{first: ts.now()}
+		ts.pending[s.TC.TID] = p
+		ts.order = append(ts.order, s.TC.TID)
+	}
+
+	if len(p.spans) >= ts.maxSpans {
+		atomic.AddUint64(&ts.dropped, 1)
+	} else {
+		p.spans = append(p.spans, s)
+	}
+
+	p.keep = p.keep || ts.interesting(s)
+	return
+}
+
+// sweep decides all traces buffered since before now less the decision wait and
+// forgets decisions older than that. A zero now decides all traces.
+func (ts *TailSampler) sweep(now time.Time) {
+	var forward []Span
+
+	ts.m.Lock()
+	deadline := now.Add(-ts.wait)
+
+	var i int
+	for ; i < len(ts.order); i++ {
+		tid := ts.order[i]
+		if !now.IsZero() && ts.pending[tid].first.After(deadline) {
+			break
+		}
+
+		forward = append(forward, ts.decide(tid, now)...)
+	}
+
+	ts.order = ts.order[i:]
+
+	for tid, d := range ts.decided {
+		if now.IsZero() || d.at.Before(deadline) {
+			delete(ts.decided, tid)
+		}
+	}
+	ts.m.Unlock()
+
+	ts.forward(forward)
+}
+
+// decide removes the trace from the buffer and returns its spans if it is kept.
+// The caller removes tid from order and must hold the lock.
+func (ts *TailSampler) decide(tid string, now time.Time) []Span {
+	p := ts.pending[tid]
+	delete(ts.pending, tid)
+
+	keep := p.keep || sampled(tid, ts.rate)
+	if !now.IsZero() {
+		ts.decided[tid] = decision{at: now, keep: keep}
+	}
+
+	if keep {
+		return p.spans
+	}
+
+	return nil
+}
+
+// interesting reports whether the span alone makes its trace worth keeping
+func (ts *TailSampler) interesting(s Span) bool {
+	return !s.Success || s.Err != nil || ts.keepCode(s.Code) ||
+		(ts.threshold > 0 && s.Duration > ts.threshold)
+}
+
+func (ts *TailSampler) forward(spans []Span) {
+	for _, s := range spans {
+		ts.next.OnFinish(s)
+	}
+}
+
+// sampled maps the trace-id onto [0, 1) and compares it against rate
+func sampled(tid string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	if rate >= 1 {
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(tid))
+	return float64(h.Sum64()) < rate*math.MaxUint64
+}
@@ -0,0 +1,190 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func traceIDs(spans []Span) (tids []string) {
+	for _, s := range spans {
+		tids = append(tids, s.TC.TID)
+	}
+	return
+}
+
+func newTestTailSampler(next SpanProcessor, now *time.Time, options ...TailSamplerOptions) *TailSampler {
+	ts := newTailSampler(next, append([]TailSamplerOptions{WithSampleRate(0)}, options...)...)
+	ts.now = func() time.Time { return *now }
+	return ts
+}
+
+func TestTailSamplerPolicies(t *testing.T) {
+	failed := createAssemblySpan("ServeHTTP", "failed", 1, 2, 0, time.Millisecond)
+	failed.Success = false
+
+	withErr := createAssemblySpan("ServeHTTP", "err", 1, 2, 0, time.Millisecond)
+	withErr.Err = errors.New("timeout")
+
+	withCode := createAssemblySpan("ServeHTTP", "code", 1, 2, 0, time.Millisecond)
+	withCode.Code = 503
+
+	notFound := createAssemblySpan("ServeHTTP", "not-found", 1, 2, 0, time.Millisecond)
+	notFound.Code = 404
+
+	slow := createAssemblySpan("ServeHTTP", "slow", 1, 2, 0, time.Millisecond)
+	slow.Duration = time.Second
+
+	tests := []struct {
+		name    string
+		span    Span
+		options []TailSamplerOptions
+		kept    bool
+	}{
+		{"Fine", createAssemblySpan("ServeHTTP", "fine", 1, 2, 0, time.Millisecond), nil, false},
+		{"Failed", failed, nil, true},
+		{"Err", withErr, nil, true},
+		{"Code", withCode, nil, true},
+		{"NotFound", notFound, nil, false},
+		{"CodePolicy", notFound, []TailSamplerOptions{WithCodePolicy(func(c int) bool { return c >= 400 })}, true},
+		{"SlowWithoutThreshold", slow, nil, false},
+		{"Slow", slow, []TailSamplerOptions{WithLatencyThreshold(500 * time.Millisecond)}, true},
+		{"Sampled", createAssemblySpan("ServeHTTP", "fine", 1, 2, 0, time.Millisecond), []TailSamplerOptions{WithSampleRate(1)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var (
+				kept []Span
+				now  = assemblyEpoch
+				next = SpanProcessorFunc(func(s Span) { kept = append(kept, s) })
+				ts   = newTestTailSampler(next, &now, test.options...)
+			)
+
+			root := createAssemblySpan("ServeHTTP", test.span.TC.TID, 1, 1, 0, time.Millisecond)
+			ts.OnFinish(root)
+			ts.OnFinish(test.span)
+
+			ts.sweep(now.Add(DefaultDecisionWait - time.Millisecond))
+			assert.Empty(kept)
+
+			ts.sweep(now.Add(DefaultDecisionWait))
+			if test.kept {
+				assert.Equal([]Span{root, test.span}, kept)
+			} else {
+				assert.Empty(kept)
+			}
+		})
+	}
+}
+
+func TestTailSamplerLateSpans(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		kept []Span
+		now  = assemblyEpoch
+		next = SpanProcessorFunc(func(s Span) { kept = append(kept, s) })
+		ts   = newTestTailSampler(next, &now, WithDecisionWait(time.Second))
+	)
+
+	failed := createAssemblySpan("ServeHTTP", "kept", 1, 1, 0, time.Millisecond)
+	failed.Success = false
+	ts.OnFinish(failed)
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "dropped", 1, 1, 0, time.Millisecond))
+
+	now = now.Add(time.Second)
+	ts.sweep(now)
+	assert.Equal([]string{"kept"}, traceIDs(kept))
+
+	// late spans follow the decision on their trace
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "kept", 1, 2, 0, time.Millisecond))
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "dropped", 1, 2, 0, time.Millisecond))
+	assert.Equal([]string{"kept", "kept"}, traceIDs(kept))
+
+	// until the decision is forgotten
+	now = now.Add(2 * time.Second)
+	ts.sweep(now)
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "dropped", 1, 3, 0, time.Millisecond))
+	now = now.Add(time.Second)
+	ts.sweep(now)
+	assert.Equal([]string{"kept", "kept"}, traceIDs(kept))
+}
+
+func TestTailSamplerBounds(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		kept []Span
+		now  = assemblyEpoch
+		next = SpanProcessorFunc(func(s Span) { kept = append(kept, s) })
+		ts   = newTestTailSampler(next, &now, WithMaxTraces(2), WithMaxSpansPerTrace(2), WithSampleRate(1))
+	)
+
+	for i := int64(1); i <= 3; i++ {
+		ts.OnFinish(createAssemblySpan("ServeHTTP", "a", 1, i, 0, time.Millisecond))
+	}
+
+	assert.Equal(uint64(1), ts.Dropped())
+
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "b", 1, 1, 0, time.Millisecond))
+	assert.Empty(kept)
+
+	// a third trace decides the oldest early
+	ts.OnFinish(createAssemblySpan("ServeHTTP", "c", 1, 1, 0, time.Millisecond))
+	assert.Equal([]string{"a", "a"}, traceIDs(kept))
+	assert.Len(ts.pending, 2)
+}
+
+func TestTailSamplerSampleRate(t *testing.T) {
+	var kept int
+	for i := 0; i < 10000; i++ {
+		if sampled(fmt.Sprintf("trace-%d", i), 0.1) {
+			kept++
+		}
+	}
+
+	assert.InDelta(t, 1000, kept, 150)
+	assert.Equal(t, sampled("abc", 0.5), sampled("abc", 0.5))
+}
+
+func TestTailSamplerClose(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		kept []Span
+		next = SpanProcessorFunc(func(s Span) { kept = append(kept, s) })
+		ts   = NewTailSampler(next, WithDecisionWait(time.Hour))
+	)
+
+	failed := createAssemblySpan("ServeHTTP", "a", 1, 1, 0, time.Millisecond)
+	failed.Success = false
+	ts.OnFinish(failed)
+	ts.Close()
+
+	assert.Equal([]Span{failed}, kept)
+
+	ts.OnFinish(failed)
+	assert.Equal(uint64(1), ts.Dropped())
+	assert.Len(kept, 1)
+}
+
+func TestTailSamplerSpanner(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		kept    []Span
+		next    = SpanProcessorFunc(func(s Span) { kept = append(kept, s) })
+		ts      = NewTailSampler(next, WithDecisionWait(time.Hour), WithSampleRate(0))
+		spanner = NewHTTPSpanner(WithSpanProcessors(ts))
+	)
+
+	tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "a", PID: 1, SID: 1}})
+	child := tracker.Start(context.Background(), Span{})
+	child.Finish(Result{Name: "query", Code: 500})
+	tracker.Finish(Result{Name: "ServeHTTP", Code: 200, Success: true})
+
+	ts.Close()
+	assert.Equal([]string{"a", "a"}, traceIDs(kept))
+}
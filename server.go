@@ -0,0 +1,128 @@
+package money
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// redactedValue replaces the values of query parameters in the http-path attribute
+const redactedValue = "redacted"
+
+// Server span attribute keys
+const (
+	methodKey         = "http-method"
+	schemeKey         = "http-scheme"
+	httpHostKey       = "http-host"
+	pathKey           = "http-path"
+	protocolKey       = "http-protocol"
+	remoteAddrKey     = "remote-addr"
+	requestLengthKey  = "request-length"
+	responseLengthKey = "response-length"
+	userAgentKey      = "user-agent"
+)
+
+// ServerAttributes selects the attributes of the request and response Decorate records on
+// server spans. They are combined by bitwise or, i.e. ServerMethod|ServerPath.
+type ServerAttributes uint
+
+const (
+	// ServerMethod records the request method as http-method
+	ServerMethod ServerAttributes = 1 << iota
+
+	// ServerScheme records http or https as http-scheme
+	ServerScheme
+
+	// ServerHost records the Host header as http-host
+	ServerHost
+
+	// ServerPath records the path as http-path. The values of query parameters are
+	// redacted, i.e. /devices?token=redacted
+	ServerPath
+
+	// ServerProtocol records the protocol, i.e. HTTP/1.1, as http-protocol
+	ServerProtocol
+
+	// ServerRemoteAddr records the address of the peer as remote-addr
+	ServerRemoteAddr
+
+	// ServerRequestLength records the length of the request body, if known, as request-length
+	ServerRequestLength
+
+	// ServerResponseLength records the number of body bytes written by the handler
+	// as response-length. As the handler usually finishes the span before it writes
+	// the body, the processors are notified of the span once the handler returned.
+	// The span in the X-MoneySpans header the handler writes lacks the attribute.
+	ServerResponseLength
+
+	// ServerUserAgent records the User-Agent header as user-agent
+	ServerUserAgent
+
+	// AllServerAttributes records all of the above
+	AllServerAttributes = ServerMethod | ServerScheme | ServerHost | ServerPath | ServerProtocol |
+		ServerRemoteAddr | ServerRequestLength | ServerResponseLength | ServerUserAgent
+)
+
+// requestAttributes returns the selected attributes of the request
+func (a ServerAttributes) requestAttributes(r *http.Request) map[string]string {
+	if a == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]string)
+
+	if a&ServerMethod != 0 {
+		attributes[methodKey] = r.Method
+	}
+
+	if a&ServerScheme != 0 {
+		attributes[schemeKey] = "http"
+		if r.TLS != nil {
+			attributes[schemeKey] = "https"
+		}
+	}
+
+	if a&ServerHost != 0 && r.Host != "" {
+		attributes[httpHostKey] = r.Host
+	}
+
+	if a&ServerPath != 0 {
+		attributes[pathKey] = redactedPath(r.URL)
+	}
+
+	if a&ServerProtocol != 0 {
+		attributes[protocolKey] = r.Proto
+	}
+
+	if a&ServerRemoteAddr != 0 && r.RemoteAddr != "" {
+		attributes[remoteAddrKey] = r.RemoteAddr
+	}
+
+	if a&ServerRequestLength != 0 && r.ContentLength >= 0 {
+		attributes[requestLengthKey] = strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	if a&ServerUserAgent != 0 && r.UserAgent() != "" {
+		attributes[userAgentKey] = r.UserAgent()
+	}
+
+	return attributes
+}
+
+// redactedPath returns the path of u followed by its query with all values redacted
+func redactedPath(u *url.URL) string {
+	query := u.Query()
+	if len(query) == 0 {
+		return u.Path
+	}
+
+	for k, vs := range query {
+		for i := range vs {
+			vs[i] = redactedValue
+		}
+
+		query[k] = vs
+	}
+
+	return u.Path + "?" + query.Encode()
+}
@@ -0,0 +1,122 @@
+package money
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecorateServerAttributes(t *testing.T) {
+	serve := func(a ServerAttributes, finishFirst bool) map[string]string {
+		var finished []Span
+
+		spanner := NewHTTPSpanner(
+			WithServerAttributes(a),
+			WithAbandonedSpans(),
+			WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })),
+		)
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tracker, _ := TrackerFromContext(r.Context())
+			if finishFirst {
+				tracker.Finish(Result{})
+			}
+
+			w.Write([]byte("hello"))
+			w.Write([]byte(" world"))
+			tracker.Finish(Result{})
+		})
+
+		r := httptest.NewRequest("POST", "https://xmidt.example.com/devices?token=secret&fields=a&fields=b", strings.NewReader("body"))
+		r.Header.Add(MoneyHeader, "trace-id=abc;parent-id=1;span-id=1")
+		r.Header.Set("User-Agent", "curl/8.0")
+		r.RemoteAddr = "10.0.0.1:4242"
+
+		spanner.Decorate("test", handler).ServeHTTP(httptest.NewRecorder(), r)
+		return finished[0].Attributes
+	}
+
+	t.Run("None", func(t *testing.T) {
+		assert.Empty(t, serve(0, false))
+	})
+
+	t.Run("All", func(t *testing.T) {
+		assert.Equal(t, map[string]string{
+			methodKey:         "POST",
+			schemeKey:         "https",
+			httpHostKey:       "xmidt.example.com",
+			pathKey:           "/devices?fields=redacted&fields=redacted&token=redacted",
+			protocolKey:       "HTTP/1.1",
+			remoteAddrKey:     "10.0.0.1:4242",
+			requestLengthKey:  "4",
+			responseLengthKey: "11",
+			userAgentKey:      "curl/8.0",
+		}, serve(AllServerAttributes, false))
+	})
+
+	t.Run("Selected", func(t *testing.T) {
+		assert.Equal(t, map[string]string{methodKey: "POST", userAgentKey: "curl/8.0"}, serve(ServerMethod|ServerUserAgent, false))
+	})
+
+	t.Run("ResponseLengthFinishedFirst", func(t *testing.T) {
+		assert.Equal(t, map[string]string{responseLengthKey: "11"}, serve(ServerResponseLength, true))
+	})
+}
+
+func TestDecorateResponseLength(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		finished []Span
+		tracker  Tracker
+	)
+
+	spanner := NewHTTPSpanner(
+		WithServerAttributes(ServerResponseLength),
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })),
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker, _ = TrackerFromContext(r.Context())
+		tracker.Finish(Result{Code: http.StatusOK, Success: true})
+		assert.Empty(finished)
+
+		w.Write([]byte("hello"))
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Add(MoneyHeader, "trace-id=abc;parent-id=1;span-id=1")
+	spanner.Decorate("test", handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Len(finished, 1)
+	assert.Equal("5", finished[0].Attributes[responseLengthKey])
+	assert.Equal([]string{finished[0].String()}, tracker.Spans())
+
+	// a span finished after the handler returned is not held
+	late := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker, _ = TrackerFromContext(r.Context())
+	})
+
+	spanner.Decorate("test", late).ServeHTTP(httptest.NewRecorder(), r)
+	tracker.Finish(Result{})
+	assert.Len(finished, 2)
+	assert.Equal("0", finished[1].Attributes[responseLengthKey])
+}
+
+func TestServerAttributesRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest("GET", "/devices", nil)
+	r.TLS, r.ContentLength = nil, -1
+	r.Host = ""
+
+	assert.Equal(map[string]string{methodKey: "GET", schemeKey: "http", pathKey: "/devices", protocolKey: "HTTP/1.1",
+		remoteAddrKey: "192.0.2.1:1234"}, AllServerAttributes.requestAttributes(r))
+
+	r.TLS = new(tls.ConnectionState)
+	assert.Equal("https", ServerScheme.requestAttributes(r)[schemeKey])
+	assert.Nil(ServerAttributes(0).requestAttributes(r))
+}
@@ -19,11 +19,51 @@ package money
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// Span keys as they appear in the Money spec
+const (
+	spanNameKey     = "span-name"
+	appNameKey      = "app-name"
+	spanDurationKey = "span-duration"
+	spanSuccessKey  = "span-success"
+	startTimeKey    = "start-time"
+	hostKey         = "host"
+	responseCodeKey = "response-code"
+	errKey          = "err"
+)
+
+// startTimeFormat is the layout used to encode span start times
+const startTimeFormat = "2006-01-02T15:04:05.999999999Z07:00"
+
+// reservedKeys cannot be used as attribute keys since they encode the span itself
+var reservedKeys = map[string]bool{
+	spanNameKey: true, appNameKey: true, spanDurationKey: true, spanSuccessKey: true,
+	startTimeKey: true, hostKey: true, responseCodeKey: true, errKey: true, linksKey: true,
+	tIDKey: true, sIDKey: true, pIDKey: true,
+}
+
+// escapedKeys are the reserved keys whose free-form values Span.String escapes like attributes
+var escapedKeys = map[string]bool{spanNameKey: true, appNameKey: true, hostKey: true, errKey: true}
+
+// attributeEscaper escapes the characters which delimit the string encoding of a span
+var attributeEscaper = strings.NewReplacer(
+	"%", "%25", ";", "%3B", "=", "%3D", ",", "%2C", "\n", "%0A", "\r", "%0D",
+)
+
+// Span map decoding errors
+var (
+	errMissingTraceContext = errors.New("span map is missing its trace context")
+	errBadSpanPair         = errors.New("expected span to have key=value pairs")
+)
+
 // Span models all the data related to a Span
 // It is a superset to what is specified in the
 // spec: https://github.com/Comcast/money/wiki#what-is-captured
@@ -40,6 +80,13 @@ type Span struct {
 	StartTime time.Time
 	Duration  time.Duration
 	Host      string
+
+	// Attributes are free-form annotations of the span. Keys used by the Money spec
+	// itself (i.e. "span-name") are not encoded.
+	Attributes map[string]string
+
+	// Links reference spans which contributed to this one besides its parent
+	Links []Link
 }
 
 // Result models the result fields of a span.
@@ -69,6 +116,12 @@ type Result struct {
 	Duration time.Duration
 
 	Host string
+
+	// Attributes are added to those the span was started with
+	Attributes map[string]string
+
+	// Links are added to those the span was started with
+	Links []Link
 }
 
 // NewSpan returns a new span instance.
@@ -81,6 +134,32 @@ func NewSpan(spanName string, tc *TraceContext) Span {
 
 type SpanMap map[string]string
 
+// MapSchema selects the set of keys used when a span is converted into a SpanMap.
+type MapSchema int
+
+const (
+	// FieldSchema keys the map by the Go field names of Span (i.e. "Name", "TC").
+	// The trace context and the attributes are each packed into a single value.
+	FieldSchema MapSchema = iota
+
+	// SpecSchema keys the map as the Money spec and Span.String do (i.e. "span-name").
+	// The trace context is flattened into trace-id, span-id and parent-id entries.
+	SpecSchema
+)
+
+// fieldToSpecKeys translates FieldSchema keys into their SpecSchema counterparts
+var fieldToSpecKeys = map[string]string{
+	"Name":      spanNameKey,
+	"AppName":   appNameKey,
+	"Duration":  spanDurationKey,
+	"Success":   spanSuccessKey,
+	"StartTime": startTimeKey,
+	"Host":      hostKey,
+	"Code":      responseCodeKey,
+	"Err":       errKey,
+	"Links":     linksKey,
+}
+
 // Changes a maps values to type string.
 func mapFieldToString(m map[string]interface{}) SpanMap {
 	n := make(map[string]string)
@@ -111,8 +190,24 @@ func mapFieldToString(m map[string]interface{}) SpanMap {
 	return n
 }
 
-// Map returns a string map representation of the span
+// Map returns a string map representation of the span keyed by FieldSchema
 func (s *Span) Map() (SpanMap, error) {
+	return s.MapWith(FieldSchema)
+}
+
+// MapWith returns a string map representation of the span keyed by the given schema
+func (s *Span) MapWith(schema MapSchema) (SpanMap, error) {
+	switch schema {
+	case FieldSchema:
+		return s.fieldMap()
+	case SpecSchema:
+		return s.specMap()
+	}
+
+	return nil, fmt.Errorf("unknown map schema %d", schema)
+}
+
+func (s *Span) fieldMap() (SpanMap, error) {
 	var m map[string]interface{}
 
 	// Receive a map of string to objects
@@ -123,31 +218,339 @@ func (s *Span) Map() (SpanMap, error) {
 
 	json.Unmarshal(r, &m)
 
-	return mapFieldToString(m), nil
+	n := mapFieldToString(m)
+
+	//ids and durations beyond 2^53 do not survive the float64 numbers of JSON
+	n["Duration"] = fmt.Sprintf("%v"+"ns", s.Duration.Nanoseconds())
+	if s.TC != nil {
+		n["TC"] = encodeTraceContext(s.TC)
+	}
+
+	if len(s.Links) > 0 {
+		n["Links"] = encodeLinks(s.Links)
+	}
+
+	if keys := s.attributeKeys(); len(keys) > 0 {
+		attributes := make(url.Values, len(keys))
+		for _, k := range keys {
+			attributes.Set(k, s.Attributes[k])
+		}
+
+		n["Attributes"] = attributes.Encode()
+	}
+
+	return n, nil
 }
 
-// String returns the string representation of the span
+// specMap mirrors String such that both representations of a span agree
+func (s *Span) specMap() (SpanMap, error) {
+	pairs, err := s.specPairs()
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(SpanMap, len(pairs))
+	for _, p := range pairs {
+		m[p[0]] = p[1]
+	}
+
+	return m, nil
+}
+
+// specPairs returns the SpecSchema key/value pairs of the span, ordered as in String
+func (s *Span) specPairs() ([][2]string, error) {
+	if s.TC == nil {
+		return nil, errMissingTraceContext
+	}
+
+	pairs := [][2]string{
+		{spanNameKey, s.Name},
+		{appNameKey, s.AppName},
+		{spanDurationKey, fmt.Sprintf("%v"+"ns", s.Duration.Nanoseconds())},
+		{spanSuccessKey, strconv.FormatBool(s.Success)},
+		{pIDKey, strconv.FormatInt(s.TC.PID, 10)},
+		{sIDKey, strconv.FormatInt(s.TC.SID, 10)},
+		{tIDKey, s.TC.TID},
+		{startTimeKey, s.StartTime.Format(startTimeFormat)},
+	}
+
+	if s.Host != "" {
+		pairs = append(pairs, [2]string{hostKey, s.Host})
+	}
+
+	if s.Code != 0 {
+		pairs = append(pairs, [2]string{responseCodeKey, strconv.Itoa(s.Code)})
+	}
+
+	if len(s.Links) > 0 {
+		pairs = append(pairs, [2]string{linksKey, encodeLinks(s.Links)})
+	}
+
+	for _, k := range s.attributeKeys() {
+		pairs = append(pairs, [2]string{k, s.Attributes[k]})
+	}
+
+	if s.Err != nil {
+		pairs = append(pairs, [2]string{errKey, s.Err.Error()})
+	}
+
+	return pairs, nil
+}
+
+// SpanFromMap is the inverse of Span.MapWith. It accepts maps keyed by either schema;
+// a map with a TC entry and no trace-id is taken to be keyed by FieldSchema. Only then
+// are field names translated, such that a SpecSchema map may hold attributes named
+// like fields. Under FieldSchema errors are only known by their placeholder value.
+func SpanFromMap(m SpanMap) (s Span, err error) {
+	var (
+		tc        = make(map[string]string)
+		_, hasTC  = m["TC"]
+		_, hasTID = m[tIDKey]
+		fields    = hasTC && !hasTID
+	)
+
+	for k, v := range m {
+		if fields {
+			switch k {
+			case "TC":
+				if s.TC, err = decodeTraceContext(v); err != nil {
+					return Span{}, err
+				}
+				continue
+			case "Attributes":
+				if err = s.decodeAttributes(v); err != nil {
+					return Span{}, err
+				}
+				continue
+			}
+
+			if sk, ok := fieldToSpecKeys[k]; ok {
+				k = sk
+			}
+		}
+
+		switch k {
+		case spanNameKey:
+			s.Name = v
+		case appNameKey:
+			s.AppName = v
+		case spanDurationKey:
+			if s.Duration, err = time.ParseDuration(v); err != nil {
+				return Span{}, err
+			}
+		case spanSuccessKey:
+			if s.Success, err = strconv.ParseBool(v); err != nil {
+				return Span{}, err
+			}
+		case startTimeKey:
+			if s.StartTime, err = time.Parse(startTimeFormat, v); err != nil {
+				return Span{}, err
+			}
+		case hostKey:
+			s.Host = v
+		case responseCodeKey:
+			if s.Code, err = strconv.Atoi(v); err != nil {
+				return Span{}, err
+			}
+		case errKey:
+			if v != "" {
+				s.Err = errors.New(v)
+			}
+		case linksKey:
+			if s.Links, err = decodeLinks(v); err != nil {
+				return Span{}, err
+			}
+		case tIDKey, sIDKey, pIDKey:
+			tc[k] = v
+		default:
+			if s.Attributes == nil {
+				s.Attributes = make(map[string]string)
+			}
+
+			s.Attributes[k] = v
+		}
+	}
+
+	if len(tc) > 0 {
+		pairs := make([]string, 0, len(tc))
+		for k, v := range tc {
+			pairs = append(pairs, k+"="+v)
+		}
+
+		if s.TC, err = decodeTraceContext(strings.Join(pairs, ";")); err != nil {
+			return Span{}, err
+		}
+	}
+
+	if s.TC == nil {
+		return Span{}, errMissingTraceContext
+	}
+
+	return
+}
+
+// decodeAttributes adds the query-encoded attributes of a FieldSchema map to the span
+func (s *Span) decodeAttributes(v string) error {
+	attributes, err := url.ParseQuery(v)
+	if err != nil {
+		return err
+	}
+
+	for k := range attributes {
+		if s.Attributes == nil {
+			s.Attributes = make(map[string]string, len(attributes))
+		}
+
+		s.Attributes[k] = attributes.Get(k)
+	}
+
+	return nil
+}
+
+// String returns the string representation of the span. Names, hosts, errors and
+// attributes are escaped such that they cannot break out of their values. The error
+// comes last, so nothing which follows it belongs to the span.
 func (s *Span) String() string {
 	var o = new(bytes.Buffer)
 
-	o.WriteString("span-name=" + s.Name)
-	o.WriteString(";app-name=" + s.AppName)
-	o.WriteString(";span-duration=" + fmt.Sprintf("%v"+"ns", s.Duration.Nanoseconds()))
-	o.WriteString(";span-success=" + strconv.FormatBool(s.Success))
+	o.WriteString(spanNameKey + "=" + attributeEscaper.Replace(s.Name))
+	o.WriteString(";" + appNameKey + "=" + attributeEscaper.Replace(s.AppName))
+	o.WriteString(";" + spanDurationKey + "=" + fmt.Sprintf("%v"+"ns", s.Duration.Nanoseconds()))
+	o.WriteString(";" + spanSuccessKey + "=" + strconv.FormatBool(s.Success))
 	o.WriteString(";" + encodeTraceContext(s.TC))
-	o.WriteString(";start-time=" + s.StartTime.Format("2006-01-02T15:04:05.999999999Z07:00"))
+	o.WriteString(";" + startTimeKey + "=" + s.StartTime.Format(startTimeFormat))
 
 	if s.Host != "" {
-		o.WriteString(";host=" + s.Host)
+		o.WriteString(";" + hostKey + "=" + attributeEscaper.Replace(s.Host))
 	}
 
 	if s.Code != 0 {
-		o.WriteString(fmt.Sprintf(";response-code=%v", s.Code))
+		o.WriteString(fmt.Sprintf(";"+responseCodeKey+"=%v", s.Code))
+	}
+
+	if len(s.Links) > 0 {
+		o.WriteString(";" + linksKey + "=" + encodeLinks(s.Links))
+	}
+
+	for _, k := range s.attributeKeys() {
+		o.WriteString(";" + attributeEscaper.Replace(k) + "=" + attributeEscaper.Replace(s.Attributes[k]))
 	}
 
 	if s.Err != nil {
-		o.WriteString(fmt.Sprintf(";err=%v", s.Err))
+		o.WriteString(";" + errKey + "=" + attributeEscaper.Replace(s.Err.Error()))
 	}
 
 	return o.String()
 }
+
+// attributeKeys returns the sorted keys of the attributes which can be encoded
+func (s *Span) attributeKeys() []string {
+	keys := make([]string, 0, len(s.Attributes))
+	for k := range s.Attributes {
+		if !reservedKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseSpan is the inverse of Span.String. It decodes a single string-encoded span
+// such as those returned by Tracker.Spans. Keys may not repeat.
+func ParseSpan(raw string) (Span, error) {
+	return parseSpan(raw, false)
+}
+
+// parseSpan decodes a single span. Values escaped by Span.String are unescaped; names,
+// hosts and errors which are not validly escaped, as from peers which did not escape
+// them, are kept as they are. When lenient, a part without '=' continues the value
+// before it, such that an error text or name holding an unescaped ';' still decodes,
+// but nothing may follow the error: an unescaped error may have made it up.
+func parseSpan(raw string, lenient bool) (Span, error) {
+	var (
+		m    = make(SpanMap)
+		last string
+	)
+
+	for _, pair := range strings.Split(strings.TrimSpace(raw), ";") {
+		kv := strings.SplitN(pair, "=", 2)
+
+		if len(kv) != 2 {
+			if lenient && reservedKeys[last] {
+				m[last] += ";" + pair
+				continue
+			}
+
+			return Span{}, errBadSpanPair
+		}
+
+		if lenient && last == errKey {
+			return Span{}, errBadSpanPair
+		}
+
+		var k, v = kv[0], kv[1]
+		switch {
+		case escapedKeys[k]:
+			if unescaped, err := url.PathUnescape(v); err == nil {
+				v = unescaped
+			}
+		case !reservedKeys[k]:
+			var err error
+			if k, err = url.PathUnescape(k); err != nil {
+				return Span{}, err
+			}
+
+			if v, err = url.PathUnescape(v); err != nil {
+				return Span{}, err
+			}
+		}
+
+		if _, ok := m[k]; ok {
+			return Span{}, errBadSpanPair
+		}
+
+		m[k], last = v, kv[0]
+	}
+
+	return SpanFromMap(m)
+}
+
+// ParseSpans decodes every span in the given value of an X-MoneySpans header.
+// Multiple spans may have been joined into a single value (i.e. by a comma).
+func ParseSpans(header string) ([]Span, error) {
+	return parseSpans(header, false)
+}
+
+func parseSpans(header string, lenient bool) (spans []Span, err error) {
+	var starts []int
+	for i := 0; ; {
+		j := strings.Index(header[i:], spanNameKey+"=")
+		if j < 0 {
+			break
+		}
+
+		//only the start of a value or a delimiter may precede a span
+		if at := i + j; at == 0 || strings.ContainsRune(";, \t", rune(header[at-1])) {
+			starts = append(starts, at)
+		}
+
+		i += j + len(spanNameKey)
+	}
+
+	for i, start := range starts {
+		var end = len(header)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		var s Span
+		if s, err = parseSpan(strings.TrimRight(header[start:end], ", \t"), lenient); err != nil {
+			return nil, err
+		}
+
+		spans = append(spans, s)
+	}
+
+	return
+}
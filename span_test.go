@@ -2,20 +2,25 @@ package money
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// stubClock starts all spans at the same time and measures them at 200ms
 type stubClock struct{}
 
-func (stubClock) Start() time.Time { return time.Now() }
+var _ Clock = stubClock{}
 
-func (stubClock) End(t time.Time) time.Duration { return time.Since(t) }
+func (stubClock) Start() time.Time { return time.Date(2019, 5, 1, 10, 30, 0, 123456789, time.UTC) }
+
+func (stubClock) End(t time.Time) time.Duration { return 200 * time.Millisecond }
 
 func createMockTC() *TraceContext {
 	return &TraceContext{
@@ -60,7 +65,6 @@ func TestMapFieldToString(t *testing.T) {
 
 	var ourClock stubClock
 	var startTime = ourClock.Start()
-	time.Sleep(200000000)
 	var duration = ourClock.End(startTime)
 
 	s.StartTime, s.Duration = startTime, duration
@@ -98,7 +102,6 @@ func TestMap(t *testing.T) {
 
 	var ourClock stubClock
 	var startTime = ourClock.Start()
-	time.Sleep(200000000)
 	var duration = ourClock.End(startTime)
 
 	s.StartTime, s.Duration = startTime, duration
@@ -127,7 +130,6 @@ func TestString(t *testing.T) {
 
 	var ourClock stubClock
 	var startTime = ourClock.Start()
-	time.Sleep(200000000)
 	var duration = ourClock.End(startTime)
 
 	var startTimeString = startTime.Format("2006-01-02T15:04:05.999999999Z07:00")
@@ -157,3 +159,284 @@ func TestString(t *testing.T) {
 
 	assert.Equal(t, s.String(), expected)
 }
+
+func TestMapWithSpecSchema(t *testing.T) {
+	s := createMockSpan()
+	s.StartTime = time.Date(2019, 5, 1, 10, 30, 0, 5, time.UTC)
+	s.Duration = 1500 * time.Millisecond
+
+	sm, err := s.MapWith(SpecSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = SpanMap{
+		"span-name":     "test-span",
+		"app-name":      "test-app",
+		"span-duration": "1500000000ns",
+		"span-success":  "true",
+		"trace-id":      "test-trace",
+		"span-id":       "1",
+		"parent-id":     "1",
+		"start-time":    "2019-05-01T10:30:00.000000005Z",
+		"host":          "localhost",
+		"response-code": "1",
+		"err":           "err",
+	}
+
+	assert.Equal(t, expected, sm)
+
+	t.Run("MissingTraceContext", func(t *testing.T) {
+		_, err := (&Span{Name: "test-span"}).MapWith(SpecSchema)
+		assert.Equal(t, errMissingTraceContext, err)
+	})
+
+	t.Run("UnknownSchema", func(t *testing.T) {
+		_, err := s.MapWith(MapSchema(-1))
+		assert.Error(t, err)
+	})
+}
+
+func TestSpanFromMap(t *testing.T) {
+	s := createMockSpan()
+	s.StartTime = time.Date(2019, 5, 1, 10, 30, 0, 5, time.UTC)
+	s.Duration = 1500 * time.Millisecond
+
+	t.Run("SpecSchema", func(t *testing.T) {
+		assert := assert.New(t)
+		sm, err := s.MapWith(SpecSchema)
+		assert.Nil(err)
+
+		actual, err := SpanFromMap(sm)
+		assert.Nil(err)
+		assert.Equal(s.String(), actual.String())
+	})
+
+	t.Run("FieldSchema", func(t *testing.T) {
+		assert := assert.New(t)
+		sm, err := s.Map()
+		assert.Nil(err)
+
+		actual, err := SpanFromMap(sm)
+		assert.Nil(err)
+		assert.Equal(*s.TC, *actual.TC)
+		assert.Equal(s.Duration, actual.Duration)
+		assert.True(s.StartTime.Equal(actual.StartTime))
+		assert.EqualError(actual.Err, "Error")
+	})
+
+	t.Run("LargeIDs", func(t *testing.T) {
+		assert := assert.New(t)
+		large := *s
+		large.TC = &TraceContext{TID: "test-trace", PID: 8674665223082153551, SID: 5577006791947779410}
+		large.Duration = 1<<53 + 1
+
+		for _, schema := range []MapSchema{FieldSchema, SpecSchema} {
+			sm, err := large.MapWith(schema)
+			assert.Nil(err)
+
+			actual, err := SpanFromMap(sm)
+			assert.Nil(err)
+			assert.Equal(*large.TC, *actual.TC)
+			assert.Equal(large.Duration, actual.Duration)
+		}
+	})
+
+	t.Run("MissingTraceContext", func(t *testing.T) {
+		_, err := SpanFromMap(SpanMap{"span-name": "test-span"})
+		assert.Equal(t, errMissingTraceContext, err)
+	})
+
+	t.Run("PartialTraceContext", func(t *testing.T) {
+		_, err := SpanFromMap(SpanMap{"trace-id": "test-trace", "span-id": "1"})
+		assert.Equal(t, errPairsCount, err)
+	})
+
+	t.Run("BadDuration", func(t *testing.T) {
+		_, err := SpanFromMap(SpanMap{"span-duration": "forever"})
+		assert.Error(t, err)
+	})
+}
+
+func TestParseSpan(t *testing.T) {
+	s := createMockSpan()
+	s.StartTime = time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+	s.Duration = 20 * time.Millisecond
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		assert := assert.New(t)
+		actual, err := ParseSpan(s.String())
+		assert.Nil(err)
+		assert.Equal(s.String(), actual.String())
+	})
+
+	t.Run("Escaped", func(t *testing.T) {
+		assert := assert.New(t)
+		escaped := *s
+		escaped.Name, escaped.AppName, escaped.Host = "GET /a;b=c", "app,1", "host;links=x"
+		escaped.Err = errors.New(`Get "http://x/a;b=c": 100% refused`)
+
+		encoded := escaped.String()
+		assert.True(strings.HasSuffix(encoded, `;err=Get "http://x/a%3Bb%3Dc": 100%25 refused`), encoded)
+
+		actual, err := ParseSpan(encoded)
+		assert.Nil(err)
+		assert.Equal(escaped.Name, actual.Name)
+		assert.Equal(escaped.AppName, actual.AppName)
+		assert.Equal(escaped.Host, actual.Host)
+		assert.Equal(escaped.Err.Error(), actual.Err.Error())
+		assert.Empty(actual.Attributes)
+		assert.Empty(actual.Links)
+	})
+
+	t.Run("Unescaped", func(t *testing.T) {
+		actual, err := ParseSpan("span-name=test-span;span-success=true;trace-id=a;parent-id=1;span-id=1;err=100% refused")
+		assert.Nil(t, err)
+		assert.EqualError(t, actual.Err, "100% refused")
+	})
+
+	t.Run("BadPair", func(t *testing.T) {
+		_, err := ParseSpan("span-name=test-span;trace-id")
+		assert.Equal(t, errBadSpanPair, err)
+	})
+
+	t.Run("DuplicateKey", func(t *testing.T) {
+		_, err := ParseSpan(s.String() + ";span-id=2")
+		assert.Equal(t, errBadSpanPair, err)
+	})
+}
+
+func TestParseSpans(t *testing.T) {
+	assert := assert.New(t)
+
+	first, second := createMockSpan(), createMockSpan()
+	second.TC = &TraceContext{TID: "test-trace", PID: 1, SID: 2}
+
+	spans, err := ParseSpans(first.String() + ", " + second.String())
+	assert.Nil(err)
+	assert.Len(spans, 2)
+	assert.Equal(first.String(), spans[0].String())
+	assert.Equal(second.String(), spans[1].String())
+
+	spans, err = ParseSpans("")
+	assert.Nil(err)
+	assert.Empty(spans)
+
+	_, err = ParseSpans("span-name=test-span;trace-id")
+	assert.Error(err)
+}
+
+func TestSpanAttributes(t *testing.T) {
+	s := createMockSpan()
+	s.Err = nil
+	s.Attributes = map[string]string{
+		"stack":     "line one;\nline=two, 100%",
+		"user":      "joe",
+		"span-name": "reserved keys are not encoded",
+	}
+
+	t.Run("String", func(t *testing.T) {
+		assert := assert.New(t)
+		encoded := s.String()
+		assert.True(strings.HasSuffix(encoded, ";response-code=1;stack=line one%3B%0Aline%3Dtwo%2C 100%25;user=joe"), encoded)
+
+		decoded, err := ParseSpan(encoded)
+		assert.Nil(err)
+		assert.Equal(map[string]string{"stack": "line one;\nline=two, 100%", "user": "joe"}, decoded.Attributes)
+		assert.Equal("test-span", decoded.Name)
+	})
+
+	t.Run("SpecSchema", func(t *testing.T) {
+		assert := assert.New(t)
+		sm, err := s.MapWith(SpecSchema)
+		assert.Nil(err)
+		assert.Equal("joe", sm["user"])
+		assert.Equal("test-span", sm["span-name"])
+
+		decoded, err := SpanFromMap(sm)
+		assert.Nil(err)
+		assert.Equal(map[string]string{"stack": "line one;\nline=two, 100%", "user": "joe"}, decoded.Attributes)
+	})
+
+	t.Run("FieldSchema", func(t *testing.T) {
+		assert := assert.New(t)
+		sm, err := s.Map()
+		assert.Nil(err)
+		assert.Equal("stack=line+one%3B%0Aline%3Dtwo%2C+100%25&user=joe", sm["Attributes"])
+
+		decoded, err := SpanFromMap(sm)
+		assert.Nil(err)
+		assert.Equal(map[string]string{"stack": "line one;\nline=two, 100%", "user": "joe"}, decoded.Attributes)
+	})
+
+	t.Run("FieldNames", func(t *testing.T) {
+		assert := assert.New(t)
+		named := *s
+		named.Attributes = map[string]string{"Code": "abc", "TC": "none", "Name": "attribute"}
+
+		for _, schema := range []MapSchema{FieldSchema, SpecSchema} {
+			m, err := named.MapWith(schema)
+			assert.Nil(err)
+
+			decoded, err := SpanFromMap(m)
+			assert.Nil(err)
+			assert.Equal(named.Attributes, decoded.Attributes)
+			assert.Equal("test-span", decoded.Name)
+			assert.Equal(1, decoded.Code)
+			assert.Equal(*s.TC, *decoded.TC)
+		}
+	})
+
+	t.Run("BadEscape", func(t *testing.T) {
+		_, err := ParseSpan(strings.Replace(s.String(), "user=joe", "user=%zz", 1))
+		assert.Error(t, err)
+	})
+}
+
+func TestSpanLinks(t *testing.T) {
+	s := createMockSpan()
+	s.Err = nil
+	s.Links = []Link{
+		{TID: "upstream;one", SID: 3, Attributes: map[string]string{"span-name": "x", "queue": "a,b&c=d"}},
+		{TID: "upstream-two", SID: 4},
+	}
+
+	expected := []Link{
+		{TID: "upstream;one", SID: 3, Attributes: map[string]string{"span-name": "x", "queue": "a,b&c=d"}},
+		{TID: "upstream-two", SID: 4},
+	}
+
+	t.Run("String", func(t *testing.T) {
+		assert := assert.New(t)
+		encoded := s.String()
+		assert.True(strings.HasSuffix(encoded,
+			";links=trace-id=upstream%3Bone&span-id=3&queue=a%2Cb%26c%3Dd&span-name=x,trace-id=upstream-two&span-id=4"), encoded)
+
+		decoded, err := ParseSpan(encoded)
+		assert.Nil(err)
+		assert.Equal(expected, decoded.Links)
+
+		// a link attribute named span-name does not start another span
+		spans, err := ParseSpans(encoded + "," + encoded)
+		assert.Nil(err)
+		assert.Len(spans, 2)
+	})
+
+	t.Run("Maps", func(t *testing.T) {
+		for _, schema := range []MapSchema{FieldSchema, SpecSchema} {
+			m, err := s.MapWith(schema)
+			assert.Nil(t, err)
+
+			decoded, err := SpanFromMap(m)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, decoded.Links)
+		}
+	})
+
+	t.Run("BadLink", func(t *testing.T) {
+		for _, v := range []string{"span-id=1", "trace-id=abc", "trace-id=abc&span-id=x", "trace-id=%zz"} {
+			_, err := ParseSpan("span-name=test;span-success=true;trace-id=a;parent-id=1;span-id=1;links=" + v)
+			assert.Error(t, err, v)
+		}
+	})
+}
@@ -3,7 +3,9 @@ package money
 import (
 	"context"
 	"net/http"
-	"time"
+	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
 // Spanner acts as the factory for spans for all downstream code.
@@ -18,17 +20,107 @@ type SpanDecoder func(*http.Request) (Span, error)
 // for HTTP spans
 type HTTPSpanner struct {
 	SD SpanDecoder
+
+	processors []SpanProcessor
+	newSpanID  func() int64
+	clock      Clock
+	policy     SuccessPolicy
+
+	panicMode  PanicMode
+	panicStack bool
+
+	finishAbandoned bool
+
+	validation *ValidationPolicy
+	trustMode  TrustMode
+	trusted    func(*http.Request) bool
+
+	filters []SpanFilter
+	namer   SpanNamer
+
+	serverAttributes ServerAttributes
+	clientTrace      bool
+	profile          bool
 }
 
 //Start defines the start time of the input span s and returns
 //a tracker object which can both start a child span for s as
 //well as mark the end of span s
+//The time left until the deadline of ctx, if any, is recorded as the
+//deadline-remaining attribute; if ctx is done by the time the span finishes,
+//its error is recorded as the context-error attribute
 func (hs *HTTPSpanner) Start(ctx context.Context, s Span) Tracker {
-	s.StartTime = time.Now()
+	return hs.start(ctx, s, false)
+}
+
+// start starts the tracker of s. A held tracker does not notify the processors of its
+// finished span before release is called.
+func (hs *HTTPSpanner) start(ctx context.Context, s Span, held bool) *HTTPTracker {
+	s.StartTime = hs.getClock().Start()
 
-	return &HTTPTracker{
-		span:    s,
+	t := &HTTPTracker{
+		m:       new(sync.RWMutex),
 		Spanner: hs,
+		hs:      hs,
+		ctx:     ctx,
+		held:    held,
+	}
+
+	if ctx != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			s.Attributes = mergeAttributes(s.Attributes, map[string]string{deadlineKey: deadline.Sub(s.StartTime).String()})
+		}
+
+		if hs.finishAbandoned {
+			t.stop = context.AfterFunc(ctx, t.abandon)
+		}
+	}
+
+	if hs.profile {
+		t.profiling = startProfiling(ctx, s)
+	}
+
+	t.span = s
+	return t
+}
+
+// getClock returns the clock which measures the spans of this spanner
+func (hs *HTTPSpanner) getClock() Clock {
+	if hs.clock == nil {
+		return systemClock{}
+	}
+
+	return hs.clock
+}
+
+// Policy returns the policy deciding the success of spans of this spanner
+func (hs *HTTPSpanner) Policy() SuccessPolicy {
+	if hs.policy == nil {
+		return DefaultSuccessPolicy
+	}
+
+	return hs.policy
+}
+
+// spanName names the server span of the request
+func (hs *HTTPSpanner) spanName(r *http.Request) string {
+	if hs.namer == nil {
+		return defaultSpanName
+	}
+
+	return hs.namer(r)
+}
+
+// subTrace creates the trace context of a child span of current
+func (hs *HTTPSpanner) subTrace(current *TraceContext) *TraceContext {
+	if hs.newSpanID == nil {
+		return SubTrace(current)
+	}
+
+	return &TraceContext{
+		PID: current.SID,
+		SID: hs.newSpanID(),
+		TID: current.TID,
 	}
 }
 
@@ -41,17 +133,48 @@ func (hs *HTTPSpanner) Decorate(appName string, next http.Handler) http.Handler
 	}
 
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-		if span, err := hs.SD(request); err == nil {
-			span.AppName, span.Name = appName, "ServeHTTP"
-			tracker := hs.Start(request.Context(), span)
+		span, err := hs.SD(request)
+		if err == nil {
+			span, err = hs.admit(request, span)
+		}
 
-			ctx := context.WithValue(request.Context(), contextKeyTracker, tracker)
+		if err == nil {
+			span.AppName, span.Name = appName, hs.spanName(request)
+			span.Attributes = mergeAttributes(span.Attributes, hs.serverAttributes.requestAttributes(request))
 
-			s := simpleResponseWriter{
+			s := &simpleResponseWriter{
 				code:           http.StatusOK,
 				ResponseWriter: response,
 			}
 
+			//the response length is only known once the handler returned, which
+			//usually is after it finished the span
+			recordLength := hs.serverAttributes&ServerResponseLength != 0
+			tracker := hs.start(request.Context(), span, recordLength)
+			if recordLength {
+				defer func() {
+					tracker.release(map[string]string{responseLengthKey: strconv.FormatInt(atomic.LoadInt64(&s.written), 10)})
+				}()
+			}
+
+			ctx := request.Context()
+			if tracker.profiling != nil {
+				//the handler need not finish the span on this goroutine, so the labels
+				//and task are cleaned up once it returned
+				ctx = tracker.profiling.ctx
+				tracker.profiling.scoped = true
+				defer func() {
+					tracker.profiling.endTask()
+					tracker.profiling.restore()
+				}()
+			}
+
+			ctx = context.WithValue(ctx, contextKeyTracker, tracker)
+
+			if hs.panicMode != 0 {
+				defer hs.recoverPanic(tracker, s)
+			}
+
 			next.ServeHTTP(s, request.WithContext(ctx))
 
 			//TODO: application and not library code should finish the above tracker
@@ -73,6 +196,125 @@ func (hs *HTTPSpanner) Decorate(appName string, next http.Handler) http.Handler
 
 type HTTPSpannerOptions func(*HTTPSpanner)
 
+// WithSpanProcessors adds processors which are notified of every finished span
+func WithSpanProcessors(processors ...SpanProcessor) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.processors = append(hs.processors, processors...)
+	}
+}
+
+// WithClock sets the clock measuring all spans started by the spanner
+// and its trackers, which is the system clock by default
+func WithClock(c Clock) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.clock = c
+	}
+}
+
+// WithSuccessPolicy sets the policy by which the Result helpers of the spanner
+// and its trackers decide success, which is DefaultSuccessPolicy by default
+func WithSuccessPolicy(p SuccessPolicy) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.policy = p
+	}
+}
+
+// WithPanicRecovery makes Decorate recover from panics of the decorated handler.
+// The span is finished as failed with code 500 and a *PanicError, then the panic
+// is either resumed or answered with a 500 response depending on mode.
+// With stack, the stack trace of the panic is added as the panic-stack attribute.
+func WithPanicRecovery(mode PanicMode, stack bool) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.panicMode, hs.panicStack = mode, stack
+	}
+}
+
+// WithAbandonedSpans finishes spans whose context is done before they are finished,
+// i.e. because the client went away, as failed with an Err wrapping ErrSpanAbandoned and
+// the context's error, and the code ErrorCode derives from it. Spans of Decorate are
+// abandoned once their handler returns without finishing them.
+func WithAbandonedSpans() HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.finishAbandoned = true
+	}
+}
+
+// WithValidationPolicy makes Decorate reject the trace contexts of incoming requests
+// which violate p, i.e. DefaultValidationPolicy; rejected requests are not traced
+func WithValidationPolicy(p ValidationPolicy) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.validation = &p
+	}
+}
+
+// WithTrustBoundary makes Decorate distrust the trace context of requests for which
+// trusted returns false, i.e. a predicate of TrustedNetworks. Their trace context is
+// either ignored or replaced by a new trace depending on mode.
+func WithTrustBoundary(mode TrustMode, trusted func(*http.Request) bool) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.trustMode, hs.trusted = mode, trusted
+	}
+}
+
+// WithSpanFilters sets filters, applied in order, which redact or drop the spans
+// returned by Tracker.Spans before they are propagated to callers.
+// Span processors still receive the unfiltered spans.
+func WithSpanFilters(filters ...SpanFilter) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.filters = append(hs.filters, filters...)
+	}
+}
+
+// WithSpanNamer sets how Decorate names server spans, which is "ServeHTTP" by default.
+// See MethodPath, NormalizedMethodPath and PatternNamer.
+func WithSpanNamer(n SpanNamer) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.namer = n
+	}
+}
+
+// WithServerAttributes selects the attributes of the request and response which
+// Decorate records on server spans, none by default
+func WithServerAttributes(a ServerAttributes) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.serverAttributes = a
+	}
+}
+
+// WithClientTrace makes DecorateTransactor trace outbound requests through net/http/httptrace
+// and record the durations of DNS lookup, connect and TLS handshake, when the connection was
+// obtained and whether it was reused, and when the first response byte arrived, as attributes
+// of the tracker's span: dns-duration, connect-duration, tls-duration, got-conn-after,
+// conn-reused and first-byte-after. They are those of the latest request of the tracker,
+// so each outbound call is best made under a tracker of its own.
+func WithClientTrace() HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.clientTrace = true
+	}
+}
+
+// WithProfilerLabels makes trackers label the goroutine starting them with the trace-id,
+// span name and app name of their span as runtime/pprof labels, such that CPU profiles can
+// be broken down by trace, and start a runtime/trace task named after the span. The task
+// is ended when the span finishes. Finish restores the labels of the context the span was
+// started with, so spans should be finished on the goroutine which started them; abandoned
+// spans leave that to Finish. Handlers decorated by Decorate receive the labels and task
+// through the request context, and Decorate restores the labels of the request context
+// and ends the task once the handler returned, wherever the handler finished the span.
+func WithProfilerLabels() HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.profile = true
+	}
+}
+
+// WithSpanIDGenerator replaces the random generation of child span-ids,
+// i.e. with a sequence that makes spans deterministic in tests
+func WithSpanIDGenerator(newSpanID func() int64) HTTPSpannerOptions {
+	return func(hs *HTTPSpanner) {
+		hs.newSpanID = newSpanID
+	}
+}
+
 func NewHTTPSpanner(options ...HTTPSpannerOptions) (spanner *HTTPSpanner) {
 	spanner = new(HTTPSpanner)
 
@@ -98,10 +340,22 @@ func NewHTTPSpanner(options ...HTTPSpannerOptions) (spanner *HTTPSpanner) {
 // simpleResponseWriter is the core decorated http.ResponseWriter.
 type simpleResponseWriter struct {
 	http.ResponseWriter
-	code int
+	code        int
+	wroteHeader bool
+	written     int64 //body bytes written, accessed atomically
 }
 
-func (rw simpleResponseWriter) WriteHeader(code int) {
-	rw.code = code
+func (rw *simpleResponseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.code, rw.wroteHeader = code, true
+	}
+
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+func (rw *simpleResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	atomic.AddInt64(&rw.written, int64(n))
+	return n, err
+}
@@ -2,14 +2,19 @@ package money
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewHTTPSpanner(t *testing.T) {
 	t.Run("DI", testNewHTTPSpannerNil)
 	t.Run("Start", testStart)
+	t.Run("Clock", testClock)
 	t.Run("DecorationNoMoneyContext", testDecorate)
 	t.Run("DecorationMoneyContext", testDecorateWithMoney)
 }
@@ -28,6 +33,32 @@ func testStart(t *testing.T) {
 	}
 }
 
+func testClock(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		clock    stubClock
+		finished []Span
+	)
+
+	spanner := NewHTTPSpanner(
+		WithClock(clock),
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })),
+	)
+
+	root := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 1}})
+	child := root.Start(context.Background(), Span{})
+	child.Finish(Result{Name: "child"})
+	root.Finish(Result{Name: "root"})
+
+	//children inherit the clock of their spanner
+	for _, s := range finished {
+		assert.Equal(clock.Start(), s.StartTime)
+		assert.Equal(200*time.Millisecond, s.Duration)
+	}
+
+	assert.Len(finished, 2)
+}
+
 func testDecorate(t *testing.T) {
 	var spanner = NewHTTPSpanner()
 
@@ -59,3 +90,88 @@ func testDecorateWithMoney(t *testing.T) {
 }
 
 //create a test that simply finishes the tracker that was started
+
+func TestDecoratePanic(t *testing.T) {
+	var (
+		failure = errors.New("nil map")
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(failure) })
+	)
+
+	newSpanner := func(finished *[]Span, options ...HTTPSpannerOptions) *HTTPSpanner {
+		return NewHTTPSpanner(append(options,
+			WithSpanProcessors(SpanProcessorFunc(func(s Span) { *finished = append(*finished, s) })))...)
+	}
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("GET", "localhost:9090/test", nil)
+		r.Header.Add(MoneyHeader, "trace-id=abc;parent-id=1;span-id=1")
+		return r
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var finished []Span
+		decorated := newSpanner(&finished).Decorate("test", handler)
+
+		assert.PanicsWithValue(t, failure, func() { decorated.ServeHTTP(httptest.NewRecorder(), newRequest()) })
+		assert.Empty(t, finished)
+	})
+
+	t.Run("RePanic", func(t *testing.T) {
+		assert := assert.New(t)
+		var finished []Span
+		decorated := newSpanner(&finished, WithPanicRecovery(RePanic, false)).Decorate("test", handler)
+
+		assert.PanicsWithValue(failure, func() { decorated.ServeHTTP(httptest.NewRecorder(), newRequest()) })
+		assert.Len(finished, 1)
+
+		s := finished[0]
+		assert.Equal("ServeHTTP", s.Name)
+		assert.Equal("test", s.AppName)
+		assert.False(s.Success)
+		assert.Equal(http.StatusInternalServerError, s.Code)
+		assert.EqualError(s.Err, "panic: nil map")
+		assert.True(errors.Is(s.Err, failure))
+		assert.Empty(s.Attributes)
+	})
+
+	t.Run("WriteServerError", func(t *testing.T) {
+		assert := assert.New(t)
+		var (
+			finished []Span
+			response = httptest.NewRecorder()
+		)
+
+		decorated := newSpanner(&finished, WithPanicRecovery(WriteServerError, true)).Decorate("test", handler)
+		decorated.ServeHTTP(response, newRequest())
+
+		assert.Equal(http.StatusInternalServerError, response.Code)
+		assert.Len(finished, 1)
+		assert.Contains(finished[0].Attributes[panicStackKey], "TestDecoratePanic")
+	})
+
+	t.Run("AfterWrite", func(t *testing.T) {
+		var (
+			finished []Span
+			response = httptest.NewRecorder()
+		)
+
+		decorated := newSpanner(&finished, WithPanicRecovery(WriteServerError, false)).Decorate("test",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("late")
+			}))
+
+		decorated.ServeHTTP(response, newRequest())
+		assert.Equal(t, http.StatusAccepted, response.Code)
+		assert.EqualError(t, finished[0].Err, "panic: late")
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		var finished []Span
+		decorated := newSpanner(&finished, WithPanicRecovery(WriteServerError, false)).Decorate("test",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }))
+
+		assert.Panics(t, func() { decorated.ServeHTTP(httptest.NewRecorder(), newRequest()) })
+		assert.Len(t, finished, 1)
+	})
+}
@@ -2,10 +2,13 @@ package money
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"os"
+	"strconv"
 	"sync"
-	"time"
 )
 
 type contextKey int
@@ -26,6 +29,19 @@ const (
 	sIDKey = "span-id"
 )
 
+//context attribute keys
+const (
+	//contextErrKey holds the error of the context of a span which was done when the span finished
+	contextErrKey = "context-error"
+
+	//deadlineKey holds the time left until the deadline of the context of a span when it started
+	deadlineKey = "deadline-remaining"
+)
+
+//ErrSpanAbandoned is the Err of spans finished under WithAbandonedSpans because their
+//context was done first. It wraps the error of the context as well.
+var ErrSpanAbandoned = errors.New("span abandoned")
+
 //Transactor is an HTTP transactor type
 type Transactor func(*http.Request) (*http.Response, error)
 
@@ -47,6 +63,14 @@ type Tracker interface {
 	Spans() []string
 }
 
+//TraceContextTracker is implemented by trackers which expose the trace context of
+//their span, such as HTTPTracker. It is kept apart from Tracker such that existing
+//implementations of Tracker remain valid.
+type TraceContextTracker interface {
+	//TraceContext returns a copy of the trace context of the managed span
+	TraceContext() *TraceContext
+}
+
 //SpanForwardingOptions allows gathering data from an HTTP response
 //into string-encoded golang money spans
 //application code is responsible to only inspect the response and if otherwise, put back data
@@ -60,11 +84,29 @@ type HTTPTracker struct {
 	m    *sync.RWMutex
 	span Span
 
+	//hs is the spanner which started this tracker and holds its configuration
+	hs *HTTPSpanner
+
 	//spans contains the string-encoded value of all spans created under this tracker
 	//should be modifiable by multiple goroutines
 	spans []string
 
 	done bool //indicates whether the span associated with this tracker is finished
+
+	//ctx is the context the tracker was started with
+	ctx context.Context
+
+	//stop unregisters the finishing of the span once ctx is done, if any
+	stop func() bool
+
+	//held defers notifying the processors of the finished span until release
+	held bool
+
+	//own is the index of the encoded span of this tracker within spans once finished
+	own int
+
+	//profiling holds the labels and task applied under WithProfilerLabels, if any
+	profiling *profiling
 }
 
 //DecorateTransactor configures a transactor to both
@@ -76,6 +118,12 @@ func (t *HTTPTracker) DecorateTransactor(transactor Transactor, options ...SpanF
 		r.Header.Add(MoneyHeader, EncodeTraceContext(t.span.TC))
 		t.m.RUnlock()
 
+		if t.hs.clientTrace {
+			timing := newClientTiming(t.hs.getClock())
+			r = r.WithContext(httptrace.WithClientTrace(r.Context(), timing.trace()))
+			defer t.setTimings(timing)
+		}
+
 		if resp, e = transactor(r); e == nil {
 			t.m.Lock()
 			defer t.m.Unlock()
@@ -106,7 +154,7 @@ func (t *HTTPTracker) Start(ctx context.Context, s Span) (tracker Tracker) {
 	defer t.m.RUnlock()
 
 	if !t.done {
-		s.TC = SubTrace(t.span.TC)
+		s.TC = t.hs.subTrace(t.span.TC)
 		tracker = t.Spanner.Start(ctx, s)
 	}
 
@@ -114,23 +162,134 @@ func (t *HTTPTracker) Start(ctx context.Context, s Span) (tracker Tracker) {
 }
 
 //Finish is an idempotent operation that marks the end of the underlying HTTPTracker span
+//The span processors of the spanner are notified once the span is finished
+//The name and app name the span was started with are kept unless the result sets them
 func (t *HTTPTracker) Finish(r Result) {
+	if s, ok := t.finish(r); ok {
+		t.notify(s)
+	}
+
+	//the labels are restored even if the span was abandoned, which happens on another goroutine
+	if t.profiling != nil && !t.profiling.scoped {
+		t.profiling.restore()
+	}
+}
+
+//notify passes the finished span to the processors of the spanner
+func (t *HTTPTracker) notify(s Span) {
+	for _, p := range t.hs.processors {
+		p.OnFinish(s)
+	}
+}
+
+//finish concludes the span, returning it if it was not finished before and
+//the processors are to be notified right away
+func (t *HTTPTracker) finish(r Result) (s Span, ok bool) {
 	t.m.Lock()
 	defer t.m.Unlock()
 
 	if !t.done {
-		t.span.Duration = time.Since(t.span.StartTime)
+		t.span.Duration = t.hs.getClock().End(t.span.StartTime)
 		t.span.Host, _ = os.Hostname()
-		t.span.Name = r.Name
-		t.span.AppName = r.AppName
+		if r.Name != "" {
+			t.span.Name = r.Name
+		}
+
+		if r.AppName != "" {
+			t.span.AppName = r.AppName
+		}
+
 		t.span.Code = r.Code
 		t.span.Err = r.Err
 		t.span.Success = r.Success
 
+		t.span.Attributes = mergeAttributes(t.span.Attributes, r.Attributes)
+		t.span.Links = appendLinks(t.span.Links, r.Links)
+
+		if t.ctx != nil && t.ctx.Err() != nil {
+			t.span.Attributes = mergeAttributes(t.span.Attributes, map[string]string{contextErrKey: t.ctx.Err().Error()})
+		}
+
+		if t.stop != nil {
+			t.stop()
+		}
+
+		if t.profiling != nil {
+			t.profiling.endTask()
+		}
+
+		if len(t.hs.filters) > 0 {
+			if _, undecodable := decodeSpans(t.spans); undecodable > 0 {
+				t.span.Attributes = mergeAttributes(t.span.Attributes, map[string]string{undecodableSpansKey: strconv.Itoa(undecodable)})
+			}
+		}
+
+		t.own = len(t.spans)
 		t.spans = append(t.spans, t.span.String())
 
 		t.done = true
+		s, ok = t.span, !t.held
 	}
+
+	return
+}
+
+//release adds the attributes to the span and lifts the hold on it, notifying the
+//processors if the span finished while held. Spans read before then lack the attributes.
+func (t *HTTPTracker) release(attributes map[string]string) {
+	t.m.Lock()
+
+	t.held = false
+	t.span.Attributes = mergeAttributes(t.span.Attributes, attributes)
+
+	finished, s := t.done, t.span
+	if finished {
+		t.spans[t.own] = t.span.String()
+	}
+
+	t.m.Unlock()
+
+	if finished {
+		t.notify(s)
+	}
+}
+
+//abandon finishes the span once its context is done before the span was finished
+func (t *HTTPTracker) abandon() {
+	err := fmt.Errorf("%w: %w", ErrSpanAbandoned, t.ctx.Err())
+
+	//not Finish, as the labels belong to the goroutine which started the span
+	if s, ok := t.finish(Result{Code: ErrorCode(err), Err: err}); ok {
+		t.notify(s)
+	}
+}
+
+//mergeAttributes returns the attributes of a overwritten by those of b
+//as a new map, leaving both unchanged
+func mergeAttributes(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+
+	attributes := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		attributes[k] = v
+	}
+
+	for k, v := range b {
+		attributes[k] = v
+	}
+
+	return attributes
+}
+
+//appendLinks returns the links of a followed by those of b as a new slice, leaving a unchanged
+func appendLinks(a, b []Link) []Link {
+	if len(b) == 0 {
+		return a
+	}
+
+	return append(append(make([]Link, 0, len(a)+len(b)), a...), b...)
 }
 
 //String returns the string representation of the span associated with this
@@ -148,11 +307,19 @@ func (t *HTTPTracker) String() (v string) {
 
 //Spans returns the list of string-encoded spans under this tracker
 //once the main span under the tracker is finished, zero value otherwise
+//The span filters of the spanner are applied to the returned spans. Spans which
+//cannot be decoded for them are dropped and counted by the undecodable-spans
+//attribute of the span of the tracker.
 func (t *HTTPTracker) Spans() (spans []string) {
 	t.m.RLock()
 	defer t.m.RUnlock()
 
 	if t.done {
+		if len(t.hs.filters) > 0 {
+			spans, _ = FilterSpans(t.spans, t.hs.filters...)
+			return
+		}
+
 		spans = make([]string, len(t.spans))
 		copy(spans, t.spans)
 	}
@@ -160,6 +327,31 @@ func (t *HTTPTracker) Spans() (spans []string) {
 	return
 }
 
+//TraceContext returns a copy of the trace context of the span associated with this tracker
+func (t *HTTPTracker) TraceContext() *TraceContext {
+	t.m.RLock()
+	defer t.m.RUnlock()
+
+	if t.span.TC == nil {
+		return nil
+	}
+
+	tc := *t.span.TC
+	return &tc
+}
+
+//Result builds the result of the span from an HTTP status and an error
+//under the success policy of the spanner
+func (t *HTTPTracker) Result(code int, err error) Result {
+	return t.hs.Policy().Result(code, err)
+}
+
+//ResponseResult builds the result of an outbound span from the output of a Transactor
+//under the success policy of the spanner
+func (t *HTTPTracker) ResponseResult(resp *http.Response, err error) Result {
+	return t.hs.Policy().ResponseResult(resp, err)
+}
+
 //TrackerFromContext extracts a tracker contained in the given context, if any
 func TrackerFromContext(ctx context.Context) (t Tracker, ok bool) {
 	t, ok = ctx.Value(contextKeyTracker).(Tracker)
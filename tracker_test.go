@@ -0,0 +1,174 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPTrackerFinish(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		finished []Span
+		ids      int64 = 10
+	)
+
+	spanner := NewHTTPSpanner(
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })),
+		WithSpanIDGenerator(func() int64 { ids++; return ids }),
+	)
+
+	root := spanner.Start(context.Background(), Span{
+		TC:         &TraceContext{TID: "abc", PID: 1, SID: 1},
+		Attributes: map[string]string{"started": "yes"},
+	})
+
+	child := root.Start(context.Background(), Span{})
+	child.Finish(Result{Name: "child", AppName: "test-app", Success: true})
+	root.Finish(Result{Name: "root", AppName: "test-app", Success: true, Attributes: map[string]string{"finished": "yes"}})
+	root.Finish(Result{Name: "again"})
+
+	assert.Len(finished, 2)
+	assert.Equal(TraceContext{TID: "abc", PID: 1, SID: 11}, *finished[0].TC)
+	assert.Equal("root", finished[1].Name)
+	assert.Equal(map[string]string{"started": "yes", "finished": "yes"}, finished[1].Attributes)
+	assert.Equal([]string{finished[1].String()}, root.Spans())
+
+	assert.Nil(root.Start(context.Background(), Span{}))
+}
+
+func TestHTTPTrackerTraceContext(t *testing.T) {
+	var spanner = NewHTTPSpanner()
+
+	tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 2}}).(TraceContextTracker)
+	tc := tracker.TraceContext()
+	assert.Equal(t, TraceContext{TID: "abc", PID: 1, SID: 2}, *tc)
+
+	tc.SID = 5
+	assert.Equal(t, int64(2), tracker.TraceContext().SID)
+
+	assert.Nil(t, spanner.Start(context.Background(), Span{}).(TraceContextTracker).TraceContext())
+}
+
+func TestHTTPTrackerResult(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		finished []Span
+	)
+
+	spanner := NewHTTPSpanner(
+		WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })),
+		WithSuccessPolicy(ServerErrorPolicy),
+	)
+
+	tracker := spanner.Start(context.Background(), Span{
+		Name:    "ServeHTTP",
+		AppName: "test-app",
+		TC:      &TraceContext{TID: "abc", PID: 1, SID: 1},
+	}).(*HTTPTracker)
+
+	tracker.Finish(tracker.Result(http.StatusNotFound, nil))
+
+	assert.Len(finished, 1)
+	assert.Equal("ServeHTTP", finished[0].Name)
+	assert.Equal("test-app", finished[0].AppName)
+	assert.Equal(http.StatusNotFound, finished[0].Code)
+	assert.True(finished[0].Success)
+
+	assert.Equal(Result{Code: http.StatusBadGateway}, tracker.ResponseResult(&http.Response{StatusCode: http.StatusBadGateway}, nil))
+}
+
+func TestHTTPTrackerContext(t *testing.T) {
+	newSpanner := func(finished chan<- Span, options ...HTTPSpannerOptions) *HTTPSpanner {
+		return NewHTTPSpanner(append(options,
+			WithClock(stubClock{}),
+			WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished <- s })))...)
+	}
+
+	newSpan := func() Span {
+		return Span{Name: "query", AppName: "test-app", TC: &TraceContext{TID: "abc", PID: 1, SID: 1}}
+	}
+
+	t.Run("Deadline", func(t *testing.T) {
+		assert := assert.New(t)
+		finished := make(chan Span, 1)
+
+		ctx, cancel := context.WithDeadline(context.Background(), stubClock{}.Start().Add(1500*time.Millisecond))
+		defer cancel()
+
+		newSpanner(finished).Start(ctx, newSpan()).Finish(Result{Code: http.StatusOK, Success: true})
+		s := <-finished
+		assert.Equal(map[string]string{deadlineKey: "1.5s", contextErrKey: "context deadline exceeded"}, s.Attributes)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		assert := assert.New(t)
+		finished := make(chan Span, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tracker := newSpanner(finished).Start(ctx, newSpan())
+		cancel()
+
+		tracker.Finish(Result{Code: http.StatusOK, Success: true})
+		s := <-finished
+		assert.Equal(map[string]string{contextErrKey: "context canceled"}, s.Attributes)
+		assert.True(s.Success)
+	})
+
+	t.Run("NotDone", func(t *testing.T) {
+		finished := make(chan Span, 1)
+
+		newSpanner(finished, WithAbandonedSpans()).Start(context.Background(), newSpan()).Finish(Result{Success: true})
+		assert.Empty(t, (<-finished).Attributes)
+	})
+
+	t.Run("Abandoned", func(t *testing.T) {
+		assert := assert.New(t)
+		finished := make(chan Span, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tracker := newSpanner(finished, WithAbandonedSpans()).Start(ctx, newSpan())
+		cancel()
+
+		s := <-finished
+		assert.Equal("query", s.Name)
+		assert.False(s.Success)
+		assert.Equal(StatusClientClosedRequest, s.Code)
+		assert.True(errors.Is(s.Err, ErrSpanAbandoned))
+		assert.True(errors.Is(s.Err, context.Canceled))
+
+		tracker.Finish(Result{Success: true})
+		assert.Empty(finished)
+	})
+
+	t.Run("FinishedBeforeCancel", func(t *testing.T) {
+		finished := make(chan Span, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		newSpanner(finished, WithAbandonedSpans()).Start(ctx, newSpan()).Finish(Result{Success: true})
+		cancel()
+
+		assert.True(t, (<-finished).Success)
+		assert.Empty(t, finished)
+	})
+}
+
+func TestHTTPTrackerLinks(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		finished []Span
+		started  = []Link{{TID: "upstream", SID: 1}}
+	)
+
+	spanner := NewHTTPSpanner(WithSpanProcessors(SpanProcessorFunc(func(s Span) { finished = append(finished, s) })))
+
+	tracker := spanner.Start(context.Background(), Span{TC: &TraceContext{TID: "abc", PID: 1, SID: 1}, Links: started})
+	tracker.Finish(Result{Name: "batch", Links: []Link{{TID: "other", SID: 2}}})
+
+	assert.Equal([]Link{{TID: "upstream", SID: 1}, {TID: "other", SID: 2}}, finished[0].Links)
+	assert.Len(started, 1)
+}
@@ -0,0 +1,154 @@
+package money
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// untrustedKey is the attribute holding the trace context an untrusted request came with
+const untrustedKey = "untrusted-trace-context"
+
+// maxUntrustedLength bounds the untrusted trace context kept as an attribute
+const maxUntrustedLength = 256
+
+// Trace context validation errors
+var (
+	ErrInvalidTraceContext = errors.New("invalid trace context")
+	errUntrusted           = errors.New("trace context of untrusted source")
+)
+
+// ValidationPolicy bounds the trace contexts accepted off incoming requests
+type ValidationPolicy struct {
+	// MaxTraceIDLength is the maximum number of bytes of a trace-id, unbounded if 0
+	MaxTraceIDLength int
+
+	// TraceIDCharset lists the characters a trace-id may consist of, any if empty
+	TraceIDCharset string
+
+	// NonZeroIDs rejects span-ids and parent-ids of 0
+	NonZeroIDs bool
+}
+
+// DefaultValidationPolicy accepts trace-ids such as UUIDs and rejects ids of 0
+var DefaultValidationPolicy = ValidationPolicy{
+	MaxTraceIDLength: 128,
+	TraceIDCharset:   "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-_.:",
+	NonZeroIDs:       true,
+}
+
+// Validate returns an error wrapping ErrInvalidTraceContext if tc violates the policy
+func (p ValidationPolicy) Validate(tc *TraceContext) error {
+	switch {
+	case tc == nil || tc.TID == "":
+		return fmt.Errorf("%w: missing trace-id", ErrInvalidTraceContext)
+	case p.MaxTraceIDLength > 0 && len(tc.TID) > p.MaxTraceIDLength:
+		return fmt.Errorf("%w: trace-id longer than %d bytes", ErrInvalidTraceContext, p.MaxTraceIDLength)
+	case p.TraceIDCharset != "" && strings.IndexFunc(tc.TID, func(r rune) bool { return !strings.ContainsRune(p.TraceIDCharset, r) }) >= 0:
+		return fmt.Errorf("%w: trace-id has characters outside of the allowed charset", ErrInvalidTraceContext)
+	case p.NonZeroIDs && (tc.SID == 0 || tc.PID == 0):
+		return fmt.Errorf("%w: span-id and parent-id must not be 0", ErrInvalidTraceContext)
+	}
+
+	return nil
+}
+
+// TrustMode selects what happens to the trace context of requests from untrusted sources
+type TrustMode int
+
+const (
+	// IgnoreUntrusted does not trace requests from untrusted sources
+	IgnoreUntrusted TrustMode = iota + 1
+
+	// ReplaceUntrusted starts a new trace for requests from untrusted sources and keeps
+	// the trace context they came with as the untrusted-trace-context attribute
+	ReplaceUntrusted
+)
+
+// TrustedNetworks returns a predicate for WithTrustBoundary which trusts requests
+// whose remote address is within any of the given CIDR prefixes, i.e. "10.0.0.0/8"
+func TrustedNetworks(cidrs ...string) (func(*http.Request) bool, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+
+		addr = addr.Unmap()
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// admit applies the trust boundary and validation policy of the spanner to the span
+// decoded off the request, returning an error if the request must not be traced
+func (hs *HTTPSpanner) admit(r *http.Request, s Span) (Span, error) {
+	if hs.trusted != nil && !hs.trusted(r) {
+		if hs.trustMode != ReplaceUntrusted {
+			return Span{}, errUntrusted
+		}
+
+		original := EncodeTraceContext(s.TC)
+		if len(original) > maxUntrustedLength {
+			original = original[:maxUntrustedLength]
+		}
+
+		s.Attributes = mergeAttributes(s.Attributes, map[string]string{untrustedKey: original})
+		s.TC = hs.newTrace()
+		return s, nil
+	}
+
+	if hs.validation != nil {
+		if err := hs.validation.Validate(s.TC); err != nil {
+			return Span{}, err
+		}
+	}
+
+	return s, nil
+}
+
+// newTrace creates the trace context of a new root span with a random UUID as trace-id
+func (hs *HTTPSpanner) newTrace() *TraceContext {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	var sid int64
+	if hs.newSpanID != nil {
+		sid = hs.newSpanID()
+	} else {
+		sid = mathrand.Int63()
+	}
+
+	return &TraceContext{
+		TID: fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]),
+		PID: sid,
+		SID: sid,
+	}
+}
@@ -0,0 +1,129 @@
+package money
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		tc    *TraceContext
+		valid bool
+	}{
+		{"UUID", &TraceContext{TID: "de305d54-75b4-431b-adb2-eb6b9e546013", PID: 1, SID: 2}, true},
+		{"Missing", nil, false},
+		{"EmptyTraceID", &TraceContext{PID: 1, SID: 2}, false},
+		{"Long", &TraceContext{TID: strings.Repeat("a", 129), PID: 1, SID: 2}, false},
+		{"Charset", &TraceContext{TID: "abc<script>", PID: 1, SID: 2}, false},
+		{"ZeroSpanID", &TraceContext{TID: "abc", PID: 1}, false},
+		{"ZeroParentID", &TraceContext{TID: "abc", SID: 1}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := DefaultValidationPolicy.Validate(test.tc)
+			if test.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrInvalidTraceContext), err)
+			}
+		})
+	}
+
+	assert.Nil(t, ValidationPolicy{}.Validate(&TraceContext{TID: strings.Repeat("<", 500)}))
+}
+
+func TestTrustedNetworks(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := TrustedNetworks("10.0.0.0/33")
+	assert.Error(err)
+
+	trusted, err := TrustedNetworks("10.1.2.3/8", "fd00::/8")
+	require.Nil(t, err)
+
+	for addr, expected := range map[string]bool{
+		"10.20.30.40:1234":       true,
+		"[::ffff:10.0.0.1]:1234": true,
+		"[fd12::1]:80":           true,
+		"192.168.1.1:1234":       false,
+		"10.0.0.1":               true,
+		"not-an-address:1234":    false,
+		"[2001:db8::1]:1234":     false,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		assert.Equal(expected, trusted(r), addr)
+	}
+}
+
+func TestDecorateTrustBoundary(t *testing.T) {
+	untrusted := func(r *http.Request) bool { return r.Header.Get("X-Internal") != "" }
+
+	serve := func(spanner *HTTPSpanner, header string, internal bool) (tc *TraceContext, attributes map[string]string) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tracker, ok := TrackerFromContext(r.Context()); ok {
+				tc = tracker.(TraceContextTracker).TraceContext()
+				attributes = tracker.(*HTTPTracker).span.Attributes
+			}
+		})
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(MoneyHeader, header)
+		if internal {
+			r.Header.Set("X-Internal", "true")
+		}
+
+		spanner.Decorate("test", handler).ServeHTTP(httptest.NewRecorder(), r)
+		return
+	}
+
+	const incoming = "trace-id=abc;parent-id=1;span-id=2"
+
+	t.Run("Ignore", func(t *testing.T) {
+		assert := assert.New(t)
+		spanner := NewHTTPSpanner(WithTrustBoundary(IgnoreUntrusted, untrusted))
+
+		tc, _ := serve(spanner, incoming, false)
+		assert.Nil(tc)
+
+		tc, _ = serve(spanner, incoming, true)
+		assert.Equal(&TraceContext{TID: "abc", PID: 1, SID: 2}, tc)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		assert := assert.New(t)
+		spanner := NewHTTPSpanner(
+			WithTrustBoundary(ReplaceUntrusted, untrusted),
+			WithSpanIDGenerator(func() int64 { return 42 }),
+		)
+
+		tc, attributes := serve(spanner, incoming, false)
+		require.NotNil(t, tc)
+		assert.Regexp("^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", tc.TID)
+		assert.Equal(int64(42), tc.SID)
+		assert.True(isRoot(tc))
+		assert.Equal(map[string]string{untrustedKey: "parent-id=1;span-id=2;trace-id=abc"}, attributes)
+
+		_, attributes = serve(spanner, "trace-id="+strings.Repeat("a", 1000)+";parent-id=1;span-id=2", false)
+		assert.Len(attributes[untrustedKey], maxUntrustedLength)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		assert := assert.New(t)
+		spanner := NewHTTPSpanner(WithValidationPolicy(DefaultValidationPolicy))
+
+		tc, _ := serve(spanner, incoming, true)
+		assert.NotNil(tc)
+
+		tc, _ = serve(spanner, "trace-id="+strings.Repeat("a", 200)+";parent-id=1;span-id=2", true)
+		assert.Nil(tc)
+	})
+}